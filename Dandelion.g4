@@ -10,7 +10,10 @@ typeline: ident=IDENT ':' typed ';';
 arglist: IDENT (',' IDENT)* (',')?;
 typelist: typed? (',' typed)*;
 typed
-    : IDENT                                 # BaseType
+    // map isn't a keyword, so it can still be used as a name
+    : {p.GetTokenStream().LT(1).GetText() == "map"}? IDENT '[' typed ']' typed # TypedMap
+    | name=IDENT '[' params=typelist ']'    # GenericType
+    | (IDENT|INTTYPE|FLOATTYPE|BYTETYPE)    # BaseType
     | 'any'                                 # AnyType
     | 'f' '(' ftypelist=typelist ')' typed  # TypedFun
     | '[' ']' typed                         # TypedArr
@@ -18,30 +21,61 @@ typed
     ;
 typedidents: IDENT ':' typed (',' IDENT ':' typed)* (',')?;
 explist: expr? (',' expr)*;
+mapentry: key=expr ':' value=expr;
+mapentries: mapentry (',' mapentry)* (',')?;
 body: lines=line*;
 structbody: lines=typeline*;
+variantline: ident=IDENT ('(' varianttypes=typelist ')')? ';';
+enumbody: lines=variantline*;
+matcharm: pattern (IF guard=expr)? '=>' ('{' body '}' | expr) ';';
+patternlist: pattern (',' pattern)* (',')?;
+fieldpattern: field=IDENT ':' pattern;
+fieldpatterns: fieldpattern (',' fieldpattern)* (',')?;
+pattern
+    : variant=IDENT '(' patterns=patternlist? ')'          # VariantPattern
+    | name=IDENT '{' fields=fieldpatterns? '}'              # StructPattern
+    | '(' patterns=patternlist ')'                          # TuplePattern
+    | bind=IDENT ':' typed                                  # TypePattern
+    | (NUMBER|FLOAT|STRING|BYTE|TRUE|FALSE)                 # LiteralPattern
+    | IDENT                                                 # BindPattern
+    ;
+elifBranch: ELIF expr '{' body '}';
+elseBranch: ELSE '{' body '}';
+catchBranch: CATCH ident=IDENT '{' body '}';
 
 expr
    : '(' expr ')'                                 # ParenExp
    | hintee=expr ':' hint=typed                   # HintExp
    | '[' elems=explist ']'                        # Array
    | '(' elems=explist ')'                        # Tuple
+   | '{' (entries=mapentries | ':') '}'           # MapLit
    | expr '.' NUMBER                              # TupleAccess
    | expr '.' IDENT                               # StructAccess
+   | expr '[' low=expr? ':' high=expr? ']'        # RangeSliceExp
    | expr '[' index=expr ']'                      # SliceExp
    | expr '.' '(' typed ')'                       # TypeAssert
    | expr 'is' typed                              # IsExp
+   | expr '(' args=explist  ')'                   # FunApp
+   | NOT expr                                     # NotExp
+   | BITWISE_NOT expr                             # ComplementExp
    | left=expr PIPE right=expr                    # PipeExp
    | expr op=(MUL|DIV) expr                       # MulDiv
+   | expr op=(LSHIFT|RSHIFT|BITWISE_AND) expr     # BitExp
    | FSTART '{' body '}'                          # FunDef
    | FSTART '(' args=arglist? ')' '{' body '}'    # FunDef
    | FSTART '(' typedargs=typedidents? ')' returntype=typed '{' body '}' # FunDef
    | 'struct' '{' structbody '}'                  # StructDef
-   | bname=(LEN|DONE|NEXT|SEND|ANY|TYPE|STR) '(' args=explist ')' # BuiltinExp
-   | expr '(' args=explist  ')'                   # FunApp
+   | IF expr '{' body '}' elifBranch* elseBranch? # If
+   | MATCH expr '{' matcharm* '}'                 # Match
+   | bname=(LEN|DONE|NEXT|SEND|ANY|TYPE|STR|EXITCODE|INTTYPE|FLOATTYPE|BYTETYPE|PARSEINT|PARSEFLOAT) '(' args=explist ')' # BuiltinExp
    | expr op=(ADD|SUB) expr                       # AddSub
+   | expr op=(BITWISE_OR|BITWISE_XOR) expr        # BitExp
    | expr MOD expr                                # ModExp
    | expr op=(LT|LTE|GT|GTE|EQ|NEQ) expr          # CompExp
+   | item=expr 'in' container=expr                # InExp
+   | expr op=AND expr                             # AndOr
+   | expr op=OR expr                              # AndOr
+   | YIELD expr                                   # Yield
    | FLOAT                                        # FloatExp
    | NUMBER                                       # Number
    | STRING                                       # StrExp
@@ -54,14 +88,18 @@ expr
 
 statement
    : expr '=' expr                           # Assign
-   | IF expr '{' lines=line* '}'             # If
-   | 'struct' ident=IDENT '{' structbody '}' # NamedStructDef
+   | 'struct' ident=IDENT ('[' params=arglist ']')? '{' structbody '}' # NamedStructDef
+   | 'enum' ident=IDENT '{' enumbody '}'     # EnumDef
+   | 'interface' ident=IDENT '{' structbody '}' # InterfaceDef
    | FOR iname=IDENT 'in' expr '{' body '}'  # ForIter
+   | FOR '(' kname=IDENT ',' vname=IDENT ')' 'in' expr '{' body '}' # ForIter
    | FOR code ';' expr ';' code '{' body '}' # For
    | WHILE expr '{' body '}'                 # While
+   | TRY '{' body '}' catchBranch            # TryCatch
+   | THROW expr                              # Throw
    | ('break' | 'continue')                  # FlowControl
    | '{' body '}'                            # BlockExp
    | RETURN expr                             # Return
-   | YIELD expr                              # Yield
    | 'extern' extname=IDENT ':' extype=typed # Extern
+   | 'import' path=STRING ('as' name=IDENT)? # Import
    ;
\ No newline at end of file
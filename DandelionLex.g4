@@ -27,6 +27,8 @@ SUB: '-';
 MOD: '%';
 BITWISE_OR: '|';
 BITWISE_AND: '&';
+BITWISE_XOR: '^';
+BITWISE_NOT: '~';
 
 // Control flow
 IF: 'if';
@@ -35,9 +37,13 @@ FOR: 'for';
 ELIF: 'elif';
 ELSE: 'else';
 PIPE: '->';
+ARROW: '=>';
 UNROLL: '->>';
 RETURN: 'return';
 YIELD: 'yield';
+TRY: 'try';
+CATCH: 'catch';
+THROW: 'throw';
 
 // Keywords
 TRUE: 'true';
@@ -50,6 +56,11 @@ CONTINUE: 'continue';
 FSTART: 'f';
 IS: 'is';
 EXTERN: 'extern';
+ENUM: 'enum';
+INTERFACE: 'interface';
+IMPORT: 'import';
+AS: 'as';
+MATCH: 'match';
 
 // Builtins
 LEN: 'len';
@@ -59,6 +70,12 @@ SEND: 'send';
 ANY: 'any';
 TYPE: 'type';
 STR: 'str';
+EXITCODE: 'exitcode';
+INTTYPE: 'int';
+FLOATTYPE: 'float';
+BYTETYPE: 'byte';
+PARSEINT: 'parseint';
+PARSEFLOAT: 'parsefloat';
 
 // Conditional ops
 OR: '||';
@@ -81,5 +98,7 @@ COMMAND: COMMAND_UNTERM '`';
 COMMAND_UNTERM: '`' (~[`\\\r\n] | '\\' (. | EOF))*;
 STRING: STRING_UNTERM '"';
 STRING_UNTERM: '"' (~["\\\r\n] | '\\' (. | EOF))*;
-NEWLINE : '\r'? '\n' { { LineCounter++ } } -> skip;
+LINE_COMMENT: ('#' | '//') ~[\r\n]* -> channel(HIDDEN);
+BLOCK_COMMENT: '/*' .*? '*/' -> channel(HIDDEN);
+NEWLINE : '\r'? '\n' { { LineCounter++ } } -> channel(HIDDEN);
 WHITESPACE: [ \t]+ -> skip;
\ No newline at end of file
@@ -28,12 +28,23 @@ func init() {
 	gob.Register(FunApp{})
 	gob.Register(FunDef{})
 	gob.Register(While{})
+	gob.Register(TryCatch{})
+	gob.Register(Throw{})
+	gob.Register(Import{})
+	gob.Register(Module{})
 	gob.Register(If{})
 	gob.Register(Mod{})
 	gob.Register(CompNode{})
+	gob.Register(AndOr{})
+	gob.Register(Not{})
+	gob.Register(BitOp{})
+	gob.Register(Complement{})
 	gob.Register(TupleLiteral{})
 	gob.Register(ArrayLiteral{})
 	gob.Register(SliceNode{})
+	gob.Register(RangeSlice{})
+	gob.Register(MapLiteral{})
+	gob.Register(InExp{})
 	gob.Register(StructDef{})
 	gob.Register(StructAccess{})
 	gob.Register(StructInstance{})
@@ -53,6 +64,17 @@ func init() {
 	gob.Register(BeginExp{})
 	gob.Register(TupleAccess{})
 	gob.Register(Extern{})
+	gob.Register(CommandExp{})
+	gob.Register(InterpStr{})
+	gob.Register(EnumDef{})
+	gob.Register(EnumInstance{})
+	gob.Register(InterfaceDef{})
+	gob.Register(InterfaceValue{})
+	gob.Register(Match{})
+	gob.Register(VariantPattern{})
+	gob.Register(TuplePattern{})
+	gob.Register(StructPattern{})
+	gob.Register(TypePattern{})
 }
 
 type NodeID int
@@ -73,6 +95,16 @@ const (
 	BuiltinDone BuiltinName = "done"
 	BuiltinType BuiltinName = "type"
 	BuiltinStr BuiltinName = "str"
+	BuiltinExitCode BuiltinName = "exitcode"
+	BuiltinInt BuiltinName = "int"
+	BuiltinFloat BuiltinName = "float"
+	BuiltinByte BuiltinName = "byte"
+	BuiltinParseInt BuiltinName = "parseint"
+	BuiltinParseFloat BuiltinName = "parsefloat"
+	// These are only builtins when the program doesn't bind its own names for them
+	BuiltinPrint BuiltinName = "print"
+	BuiltinArgv BuiltinName = "args"
+	BuiltinEnv BuiltinName = "env"
 )
 
 var BuiltinArgs = map[BuiltinName]int{
@@ -83,12 +115,28 @@ var BuiltinArgs = map[BuiltinName]int{
 	BuiltinDone: 1,
 	BuiltinType: 1,
 	BuiltinStr: 1,
+	BuiltinExitCode: 0,
+	BuiltinInt: 1,
+	BuiltinFloat: 1,
+	BuiltinByte: 1,
+	BuiltinParseInt: 1,
+	BuiltinParseFloat: 1,
+}
+
+// NamedBuiltins are the builtins that are called like functions, with their arg counts. print takes any number of args.
+var NamedBuiltins = map[BuiltinName]int{
+	BuiltinPrint: -1,
+	BuiltinArgv: 0,
+	BuiltinEnv: 1,
 }
 
 type Program struct {
 	Funcs       map[string]*FunDef
 	structs     map[string]*StructDef
 	structOrder []*StructDef
+	enums       map[string]*EnumDef
+	enumOrder   []*EnumDef
+	interfaces  map[string]*InterfaceDef
 	Metadata    map[NodeID]*Meta
 	RefTypes    map[types.TypeHash]types.Type // Types that are referenced in the program, even if no expression has that type
 	CurrNodeID  NodeID
@@ -99,6 +147,8 @@ func NewProgram() *Program {
 	newProg := &Program{}
 	newProg.Funcs = make(map[string]*FunDef)
 	newProg.structs = make(map[string]*StructDef)
+	newProg.enums = make(map[string]*EnumDef)
+	newProg.interfaces = make(map[string]*InterfaceDef)
 	newProg.Metadata = make(map[NodeID]*Meta)
 	newProg.RefTypes = make(map[types.TypeHash]types.Type)
 
@@ -107,7 +157,7 @@ func NewProgram() *Program {
 
 func (p *Program) Struct(name string) *StructDef {
 	for _, sDef := range p.structs {
-		if sDef.Type.Name == name {
+		if sDef.Type.TypeString() == name {
 			return sDef
 		}
 	}
@@ -128,6 +178,42 @@ func (p *Program) AddStruct(name string, newStruct *StructDef) {
 	p.structOrder = append(p.structOrder, newStruct)
 }
 
+func (p *Program) Enum(name string) *EnumDef {
+	return p.enums[name]
+}
+
+// VariantEnum returns the enum that declares the variant, or nil if no enum does
+func (p *Program) VariantEnum(variant string) *EnumDef {
+	for _, enumDef := range p.enumOrder {
+		if enumDef.Variant(variant) != nil {
+			return enumDef
+		}
+	}
+
+	return nil
+}
+
+func (p *Program) EnumNo(index int) *EnumDef {
+	return p.enumOrder[index]
+}
+
+func (p *Program) EnumCount() int {
+	return len(p.enumOrder)
+}
+
+func (p *Program) AddEnum(newEnum *EnumDef) {
+	p.enums[newEnum.Type.Name] = newEnum
+	p.enumOrder = append(p.enumOrder, newEnum)
+}
+
+func (p *Program) Interface(name string) *InterfaceDef {
+	return p.interfaces[name]
+}
+
+func (p *Program) AddInterface(newInterface *InterfaceDef) {
+	p.interfaces[newInterface.Type.Name] = newInterface
+}
+
 func (p *Program) Meta(node Node) *Meta {
 	if node == nil {
 		return nil
@@ -135,6 +221,12 @@ func (p *Program) Meta(node Node) *Meta {
 	return p.Metadata[node.ID()]
 }
 
+// Discarded returns whether the value of a node is thrown away
+func (p *Program) Discarded(node Node) bool {
+	meta := p.Meta(node)
+	return meta != nil && meta.Discarded
+}
+
 func (p *Program) NewNodeID() NodeID {
 	p.CurrNodeID++
 
@@ -145,8 +237,10 @@ func (p *Program) NewNodeID() NodeID {
 }
 
 type Meta struct {
-	LineNo int
-	Hint   types.Type
+	LineNo    int
+	Hint      types.Type
+	Doc       string // Text of the comments directly before a function, struct or enum definition
+	Discarded bool   // Set on ifs and yields that are only used as statements, so their values are thrown away
 }
 
 type Block struct {
@@ -192,6 +286,52 @@ func (n *Mod) String() string {
 	return fmt.Sprintf("%v %% %v", n.Left, n.Right)
 }
 
+// AndOr is a short-circuiting logical operator, either && or ||
+type AndOr struct {
+	Left  Node
+	Right Node
+	Op    string
+	NodeID
+}
+
+func (n *AndOr) String() string {
+	return fmt.Sprintf("%v %s %v", n.Left, n.Op, n.Right)
+}
+
+type Not struct {
+	Target Node
+	NodeID
+}
+
+func (n *Not) String() string {
+	return fmt.Sprintf("!%v", n.Target)
+}
+
+// BitOp is a bitwise operator: &, |, ^, << or >>
+type BitOp struct {
+	Left  Node
+	Right Node
+	Op    string
+	NodeID
+}
+
+func (n *BitOp) String() string {
+	return fmt.Sprintf("%v %s %v", n.Left, n.Op, n.Right)
+}
+
+func (n *BitOp) IsShift() bool {
+	return n.Op == "<<" || n.Op == ">>"
+}
+
+type Complement struct {
+	Target Node
+	NodeID
+}
+
+func (n *Complement) String() string {
+	return fmt.Sprintf("~%v", n.Target)
+}
+
 type ParenExp struct {
 	Exp Node
 	NodeID
@@ -261,6 +401,30 @@ func (n *Extern) String() string {
 	return fmt.Sprintf("extern %s: %s", n.Name, n.Type.TypeString())
 }
 
+// Import makes the exported names of a module available in the importing file as Name.x
+type Import struct {
+	Path string
+	Name string
+	NodeID
+}
+
+func (n *Import) String() string {
+	return fmt.Sprintf("import \"%s\" as %s", n.Path, n.Name)
+}
+
+// Module is the top level of an imported file, or of all the files in an imported directory.
+// Modules run before the file that imports them, and each one only runs once.
+type Module struct {
+	Name string
+	Path string
+	Body *Block
+	NodeID
+}
+
+func (n *Module) String() string {
+	return fmt.Sprintf("module %s {\n%s}", n.Name, n.Body)
+}
+
 type FunDef struct {
 	Body     *Block
 	Args     []Node
@@ -321,9 +485,15 @@ type StructDef struct {
 	Members []*StructMember
 	Methods []*StructMethod // Methods are discovered during function removal
 	Type    types.StructType
+	Params  []string // Type parameters, if the struct is generic
 	NodeID
 }
 
+// Generic returns true if the struct has type parameters. Only its instances, like Stack[int], are real types.
+func (d *StructDef) Generic() bool {
+	return len(d.Params) > 0
+}
+
 func (d *StructDef) Method(name string) *StructMethod {
 	for _, method := range d.Methods {
 		if method.Name == name {
@@ -396,6 +566,116 @@ func (n *StructDef) Offset(offsetName string) int {
 	return structOffset
 }
 
+type EnumVariant struct {
+	Name  string
+	Types []types.Type
+}
+
+func (v *EnumVariant) String() string {
+	if len(v.Types) == 0 {
+		return v.Name
+	}
+
+	typeStrings := make([]string, 0)
+	for _, ty := range v.Types {
+		typeStrings = append(typeStrings, ty.TypeString())
+	}
+
+	return fmt.Sprintf("%s(%s)", v.Name, strings.Join(typeStrings, ", "))
+}
+
+// EnumDef declares a tagged union. Every value of the enum is one of its variants, along with
+// the payload values that variant carries.
+type EnumDef struct {
+	Variants []*EnumVariant
+	Type     types.EnumType
+	NodeID
+}
+
+func (d *EnumDef) Variant(name string) *EnumVariant {
+	for _, variant := range d.Variants {
+		if variant.Name == name {
+			return variant
+		}
+	}
+
+	return nil
+}
+
+// Tag returns the number that identifies the variant at runtime
+func (d *EnumDef) Tag(name string) int {
+	for i, variant := range d.Variants {
+		if variant.Name == name {
+			return i
+		}
+	}
+
+	panic("Unknown variant name: " + name)
+}
+
+func (d *EnumDef) String() string {
+	variants := make([]string, 0)
+	for _, variant := range d.Variants {
+		variants = append(variants, "    "+variant.String())
+	}
+
+	return fmt.Sprintf("enum %s {\n%s\n}", d.Type.Name, strings.Join(variants, "\n"))
+}
+
+// InterfaceDef declares an interface, the methods that a struct needs to be used as it
+type InterfaceDef struct {
+	Methods []*StructMember
+	Type    types.InterfaceType
+	NodeID
+}
+
+// MethodIndex returns the position of a method in the interface's method table
+func (d *InterfaceDef) MethodIndex(name string) int {
+	for i, method := range d.Methods {
+		if method.Name.Value == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (d *InterfaceDef) String() string {
+	methods := make([]string, 0)
+	for _, method := range d.Methods {
+		methods = append(methods, "    "+method.String())
+	}
+
+	return fmt.Sprintf("interface %s {\n%s\n}", d.Type.Name, strings.Join(methods, "\n"))
+}
+
+// InterfaceValue converts a struct to an interface, like 'Named(dog)'
+type InterfaceValue struct {
+	Value Node
+	Type  types.InterfaceType
+	NodeID
+}
+
+func (n *InterfaceValue) String() string {
+	return fmt.Sprintf("%s(%v)", n.Type.Name, n.Value)
+}
+
+type EnumInstance struct {
+	Variant string
+	Values  []Node
+	DefRef  *EnumDef
+	NodeID
+}
+
+func (n *EnumInstance) String() string {
+	valueStrings := make([]string, 0)
+	for _, value := range n.Values {
+		valueStrings = append(valueStrings, value.String())
+	}
+
+	return fmt.Sprintf("%s(%s)", n.Variant, strings.Join(valueStrings, ", "))
+}
+
 type LineBundle struct {
 	Lines []Node
 	NodeID
@@ -482,9 +762,37 @@ func (n *While) String() string {
 	return lines
 }
 
+// TryCatch runs Body, and runs Catch with Err bound to the error's message if anything in Body throws
+type TryCatch struct {
+	Body  *Block
+	Err   *Ident
+	Catch *Block
+	NodeID
+}
+
+func (n *TryCatch) String() string {
+	lines := "try {\n"
+	lines += n.Body.String()
+	lines += fmt.Sprintf("} catch %v {\n", n.Err)
+	lines += n.Catch.String()
+	lines += "}"
+
+	return lines
+}
+
+type Throw struct {
+	Value Node
+	NodeID
+}
+
+func (n *Throw) String() string {
+	return fmt.Sprintf("throw %v", n.Value)
+}
+
 type If struct {
 	Cond Node
 	Body *Block
+	Else *Block // Nil when there is no else branch. Elif branches are nested ifs inside the else block.
 	NodeID
 }
 
@@ -493,9 +801,194 @@ func (n *If) String() string {
 	lines += n.Body.String()
 	lines += "}"
 
+	if elif := n.Elif(); elif != nil {
+		lines += " el" + elif.String()
+	} else if n.Else != nil {
+		lines += " else {\n"
+		lines += n.Else.String()
+		lines += "}"
+	}
+
 	return lines
 }
 
+// Elif returns the nested if when the else branch is an elif
+func (n *If) Elif() *If {
+	if n.Else == nil || len(n.Else.Lines) != 1 {
+		return nil
+	}
+
+	elif, isIf := n.Else.Lines[0].(*If)
+	if !isIf {
+		return nil
+	}
+	return elif
+}
+
+// HasValue returns true if the if can be used as an expression, which requires an else branch
+// and every branch to end with an expression.
+func (n *If) HasValue() bool {
+	if n.Else == nil {
+		return false
+	}
+
+	for _, branch := range n.Branches() {
+		if len(branch.Lines) == 0 || Statement(branch.Lines[len(branch.Lines)-1]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Branches returns the body and else blocks of the if
+func (n *If) Branches() []*Block {
+	if n.Else == nil {
+		return []*Block{n.Body}
+	}
+
+	return []*Block{n.Body, n.Else}
+}
+
+// Match evaluates the body of the first arm whose pattern matches Target
+type Match struct {
+	Target Node
+	Arms   []*MatchArm
+	NodeID
+}
+
+// MatchArm is a single case of a match. An identifier pattern matches any value and binds it to that name.
+// If the arm has a guard, the arm is only taken when the guard is also true.
+type MatchArm struct {
+	Pattern Node
+	Guard   Node
+	Body    *Block
+}
+
+func (n *Match) String() string {
+	lines := fmt.Sprintf("match %v {\n", n.Target)
+	for _, arm := range n.Arms {
+		if arm.Guard != nil {
+			lines += fmt.Sprintf("%v if %v => {\n", arm.Pattern, arm.Guard)
+		} else {
+			lines += fmt.Sprintf("%v => {\n", arm.Pattern)
+		}
+		lines += arm.Body.String()
+		lines += "}\n"
+	}
+	lines += "}"
+
+	return lines
+}
+
+// HasValue returns true if the match can be used as an expression, which requires every arm to end with an expression
+func (n *Match) HasValue() bool {
+	for _, arm := range n.Arms {
+		if len(arm.Body.Lines) == 0 || Statement(arm.Body.Lines[len(arm.Body.Lines)-1]) {
+			return false
+		}
+	}
+
+	return len(n.Arms) > 0
+}
+
+// VariantPattern matches one variant of an enum, and matches its payload values against Patterns
+type VariantPattern struct {
+	Variant  string
+	Patterns []Node
+	NodeID
+}
+
+func (n *VariantPattern) String() string {
+	if len(n.Patterns) == 0 {
+		return n.Variant
+	}
+
+	return fmt.Sprintf("%s(%s)", n.Variant, patternStrings(n.Patterns))
+}
+
+// TuplePattern matches each element of a tuple against the pattern in the same position
+type TuplePattern struct {
+	Elems []Node
+	NodeID
+}
+
+func (n *TuplePattern) String() string {
+	return fmt.Sprintf("(%s)", patternStrings(n.Elems))
+}
+
+// StructPattern matches the named members of a struct. Members that aren't named match any value.
+type StructPattern struct {
+	Name     string
+	Fields   []string
+	Patterns []Node
+	NodeID
+}
+
+func (n *StructPattern) String() string {
+	fields := make([]string, 0)
+	for i, field := range n.Fields {
+		fields = append(fields, fmt.Sprintf("%s: %v", field, n.Patterns[i]))
+	}
+
+	return fmt.Sprintf("%s{%s}", n.Name, strings.Join(fields, ", "))
+}
+
+// TypePattern matches an any value holding Type, and binds the unwrapped value to Bind
+type TypePattern struct {
+	Bind *Ident
+	Type types.Type
+	NodeID
+}
+
+func (n *TypePattern) String() string {
+	return fmt.Sprintf("%v: %s", n.Bind, n.Type.TypeString())
+}
+
+func patternStrings(patterns []Node) string {
+	strs := make([]string, 0)
+	for _, pattern := range patterns {
+		strs = append(strs, pattern.String())
+	}
+
+	return strings.Join(strs, ", ")
+}
+
+// LiteralPattern returns true if the pattern is a literal that matches values equal to it
+func LiteralPattern(pattern Node) bool {
+	switch pattern.(type) {
+	case *Num, *FloatExp, *StrExp, *ByteExp, *BoolExp:
+		return true
+	}
+
+	return false
+}
+
+// PatternBinds returns the identifiers that a match pattern binds
+func PatternBinds(pattern Node) []*Ident {
+	binds := make([]*Ident, 0)
+	switch p := pattern.(type) {
+	case *Ident:
+		binds = append(binds, p)
+	case *TypePattern:
+		binds = append(binds, p.Bind)
+	case *VariantPattern:
+		for _, sub := range p.Patterns {
+			binds = append(binds, PatternBinds(sub)...)
+		}
+	case *TuplePattern:
+		for _, sub := range p.Elems {
+			binds = append(binds, PatternBinds(sub)...)
+		}
+	case *StructPattern:
+		for _, sub := range p.Patterns {
+			binds = append(binds, PatternBinds(sub)...)
+		}
+	}
+
+	return binds
+}
+
 type For struct {
 	Init Node
 	Cond Node
@@ -598,6 +1091,40 @@ func (n *ArrayLiteral) String() string {
 	return arrStr
 }
 
+type MapLiteral struct {
+	Keys   []Node
+	Values []Node
+	// Like empty arrays, empty maps are numbered so the type checker can tell them apart
+	EmptyNo int
+	NodeID
+}
+
+func (n *MapLiteral) String() string {
+	entryStrings := make([]string, 0)
+	for k, key := range n.Keys {
+		entryStrings = append(entryStrings, fmt.Sprintf("%v: %v", key, n.Values[k]))
+	}
+
+	mapStr := "{" + strings.Join(entryStrings, ", ") + "}"
+
+	if n.EmptyNo > 0 {
+		mapStr += fmt.Sprintf("#%d", n.EmptyNo)
+	}
+
+	return mapStr
+}
+
+// InExp checks whether Item is a key of the Container map
+type InExp struct {
+	Item      Node
+	Container Node
+	NodeID
+}
+
+func (n *InExp) String() string {
+	return fmt.Sprintf("%v in %v", n.Item, n.Container)
+}
+
 type BuiltinExp struct {
 	Args []Node
 	Type BuiltinName
@@ -640,6 +1167,27 @@ func (n *SliceNode) String() string {
 	return fmt.Sprintf("%v[%v]", n.Arr, n.Index)
 }
 
+// RangeSlice takes the elements from Low up to, but not including, High. Either bound
+// may be nil, in which case it defaults to the start or end of Arr.
+type RangeSlice struct {
+	Low  Node
+	High Node
+	Arr  Node
+	NodeID
+}
+
+func (n *RangeSlice) String() string {
+	low, high := "", ""
+	if n.Low != nil {
+		low = n.Low.String()
+	}
+	if n.High != nil {
+		high = n.High.String()
+	}
+
+	return fmt.Sprintf("%v[%s:%s]", n.Arr, low, high)
+}
+
 type TupleAccess struct {
 	Index int
 	Tup Node
@@ -697,14 +1245,42 @@ func (n *Pipeline) String() string {
 	return "(" + strings.Join(segStrs, " -> ") + ")"
 }
 
+// CommandExp runs a program and evaluates to its stdout. The first arg is the program name,
+// and every arg evaluates to a string.
 type CommandExp struct {
-	Command string
-	Args    []string
+	Args []Node
 	NodeID
 }
 
 func (n *CommandExp) String() string {
-	return fmt.Sprintf("`%s`", n.Command)
+	argStrings := make([]string, 0)
+	for _, arg := range n.Args {
+		argStrings = append(argStrings, arg.String())
+	}
+
+	return fmt.Sprintf("`%s`", strings.Join(argStrings, " "))
+}
+
+// InterpStr is a string literal with embedded expressions. Parts alternate freely between
+// literal StrExps and interpolated values, which are formatted and joined in order.
+type InterpStr struct {
+	Parts []Node
+	NodeID
+}
+
+func (n *InterpStr) String() string {
+	var builder strings.Builder
+	builder.WriteString("\"")
+	for _, part := range n.Parts {
+		if str, ok := part.(*StrExp); ok {
+			builder.WriteString(str.Value)
+		} else {
+			builder.WriteString("{" + part.String() + "}")
+		}
+	}
+	builder.WriteString("\"")
+
+	return builder.String()
 }
 
 type ReturnExp struct {
@@ -796,17 +1372,25 @@ func HashNode(node Node) NodeHash {
 }
 
 func Statement(node Node) bool {
-	switch node.(type) {
+	switch n := node.(type) {
 	case *Assign:
 		return true
 	case *ReturnExp:
 		return true
-	case *YieldExp:
-		return true
 	case *If:
-		return true
+		return !n.HasValue()
+	case *Match:
+		return !n.HasValue()
 	case *While:
 		return true
+	case *TryCatch:
+		return true
+	case *Throw:
+		return true
+	case *Import:
+		return true
+	case *Module:
+		return true
 	case *For:
 		return true
 	case *ForIter:
@@ -817,6 +1401,8 @@ func Statement(node Node) bool {
 		return true
 	case *Extern:
 		return true
+	case *EnumDef:
+		return true
 	}
 
 	return false
@@ -867,18 +1453,40 @@ func SetID(astNode Node, newID NodeID) {
 		node.NodeID = newID
 	case *While:
 		node.NodeID = newID
+	case *TryCatch:
+		node.NodeID = newID
+	case *Throw:
+		node.NodeID = newID
+	case *Import:
+		node.NodeID = newID
+	case *Module:
+		node.NodeID = newID
 	case *If:
 		node.NodeID = newID
 	case *Mod:
 		node.NodeID = newID
 	case *CompNode:
 		node.NodeID = newID
+	case *AndOr:
+		node.NodeID = newID
+	case *Not:
+		node.NodeID = newID
+	case *BitOp:
+		node.NodeID = newID
+	case *Complement:
+		node.NodeID = newID
 	case *TupleLiteral:
 		node.NodeID = newID
 	case *ArrayLiteral:
 		node.NodeID = newID
 	case *SliceNode:
 		node.NodeID = newID
+	case *RangeSlice:
+		node.NodeID = newID
+	case *MapLiteral:
+		node.NodeID = newID
+	case *InExp:
+		node.NodeID = newID
 	case *StructAccess:
 		node.NodeID = newID
 	case *StructInstance:
@@ -915,6 +1523,28 @@ func SetID(astNode Node, newID NodeID) {
 		node.NodeID = newID
 	case *Extern:
 		node.NodeID = newID
+	case *CommandExp:
+		node.NodeID = newID
+	case *InterpStr:
+		node.NodeID = newID
+	case *EnumDef:
+		node.NodeID = newID
+	case *EnumInstance:
+		node.NodeID = newID
+	case *InterfaceDef:
+		node.NodeID = newID
+	case *InterfaceValue:
+		node.NodeID = newID
+	case *Match:
+		node.NodeID = newID
+	case *VariantPattern:
+		node.NodeID = newID
+	case *TuplePattern:
+		node.NodeID = newID
+	case *StructPattern:
+		node.NodeID = newID
+	case *TypePattern:
+		node.NodeID = newID
 	default:
 		panic("SetID not defined for type:" + reflect.TypeOf(astNode).String())
 	}
@@ -53,7 +53,9 @@ func WalkAst(astNode Node, w AstWalker) Node {
 	case *TupleLiteral:
 		retVal = &TupleLiteral{WalkList(node.Exprs, w), node.NodeID}
 	case *CommandExp:
-		retVal = &CommandExp{node.Command, node.Args, node.NodeID}
+		retVal = &CommandExp{WalkList(node.Args, w), node.NodeID}
+	case *InterpStr:
+		retVal = &InterpStr{WalkList(node.Parts, w), node.NodeID}
 	case *MulDiv:
 		retVal = &MulDiv{WalkAst(node.Left, w), WalkAst(node.Right, w), node.Op, node.NodeID}
 	case *Mod:
@@ -71,6 +73,10 @@ func WalkAst(astNode Node, w AstWalker) Node {
 		retVal = &FunApp{WalkAst(node.Fun, w), walkedArgs, node.Extern, node.NodeID}
 	case *While:
 		retVal = &While{WalkAst(node.Cond, w), WalkBlock(node.Body, w), node.NodeID}
+	case *TryCatch:
+		retVal = &TryCatch{WalkBlock(node.Body, w), WalkAst(node.Err, w).(*Ident), WalkBlock(node.Catch, w), node.NodeID}
+	case *Throw:
+		retVal = &Throw{WalkAst(node.Value, w), node.NodeID}
 	case *For:
 		retVal = &For{WalkAst(node.Init, w), WalkAst(node.Cond, w), WalkAst(node.Step, w), WalkBlock(node.Body, w), node.NodeID}
 	case *ForIter:
@@ -89,28 +95,80 @@ func WalkAst(astNode Node, w AstWalker) Node {
 
 		retVal = &StructInstance{newDefaults, node.DefRef, node.NodeID}
 	case *StructDef:
-		retVal = &StructDef{node.Members, node.Methods, node.Type, node.NodeID}
+		retVal = &StructDef{node.Members, node.Methods, node.Type, node.Params, node.NodeID}
 	case *StructAccess:
 		field := node.Field.(*Ident)
 		retVal = &StructAccess{&Ident{field.Value, field.NodeID}, WalkAst(node.Target, w), node.NodeID}
 	case *BuiltinExp:
 		retVal = &BuiltinExp{WalkList(node.Args, w), node.Type, node.NodeID}
 	case *If:
-		retVal = &If{WalkAst(node.Cond, w), WalkBlock(node.Body, w), node.NodeID}
+		newIf := &If{WalkAst(node.Cond, w), WalkBlock(node.Body, w), nil, node.NodeID}
+		if node.Else != nil {
+			newIf.Else = WalkBlock(node.Else, w)
+		}
+		retVal = newIf
 	case *ReturnExp:
 		retVal = &ReturnExp{WalkAst(node.Target, w), node.SourceFunc, node.NodeID}
 	case *YieldExp:
 		retVal = &YieldExp{WalkAst(node.Target, w), node.SourceFunc, node.NodeID}
 	case *CompNode:
 		retVal = &CompNode{node.Op, WalkAst(node.Left, w), WalkAst(node.Right, w), node.NodeID}
+	case *AndOr:
+		retVal = &AndOr{WalkAst(node.Left, w), WalkAst(node.Right, w), node.Op, node.NodeID}
+	case *Not:
+		retVal = &Not{WalkAst(node.Target, w), node.NodeID}
+	case *BitOp:
+		retVal = &BitOp{WalkAst(node.Left, w), WalkAst(node.Right, w), node.Op, node.NodeID}
+	case *Complement:
+		retVal = &Complement{WalkAst(node.Target, w), node.NodeID}
 	case *ArrayLiteral:
 		retVal = &ArrayLiteral{node.Length, WalkList(node.Exprs, w), node.EmptyNo, node.NodeID}
 	case *SliceNode:
 		retVal = &SliceNode{WalkAst(node.Index, w), WalkAst(node.Arr, w), node.NodeID}
+	case *RangeSlice:
+		newSlice := &RangeSlice{}
+		if node.Low != nil {
+			newSlice.Low = WalkAst(node.Low, w)
+		}
+		if node.High != nil {
+			newSlice.High = WalkAst(node.High, w)
+		}
+		newSlice.Arr = WalkAst(node.Arr, w)
+		newSlice.NodeID = node.NodeID
+		retVal = newSlice
+	case *MapLiteral:
+		retVal = &MapLiteral{WalkList(node.Keys, w), WalkList(node.Values, w), node.EmptyNo, node.NodeID}
+	case *InExp:
+		retVal = &InExp{WalkAst(node.Item, w), WalkAst(node.Container, w), node.NodeID}
 	case *TupleAccess:
 		retVal = &TupleAccess{node.Index,WalkAst(node.Tup, w), node.NodeID}
 	case *Extern:
 		retVal = &Extern{node.Name, node.Type, node.NodeID}
+	case *EnumDef:
+		retVal = &EnumDef{node.Variants, node.Type, node.NodeID}
+	case *EnumInstance:
+		retVal = &EnumInstance{node.Variant, WalkList(node.Values, w), node.DefRef, node.NodeID}
+	case *InterfaceDef:
+		retVal = &InterfaceDef{node.Methods, node.Type, node.NodeID}
+	case *InterfaceValue:
+		retVal = &InterfaceValue{WalkAst(node.Value, w), node.Type, node.NodeID}
+	case *Match:
+		newArms := make([]*MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			newArms[i] = &MatchArm{WalkAst(arm.Pattern, w), nil, WalkBlock(arm.Body, w)}
+			if arm.Guard != nil {
+				newArms[i].Guard = WalkAst(arm.Guard, w)
+			}
+		}
+		retVal = &Match{WalkAst(node.Target, w), newArms, node.NodeID}
+	case *VariantPattern:
+		retVal = &VariantPattern{node.Variant, WalkList(node.Patterns, w), node.NodeID}
+	case *TuplePattern:
+		retVal = &TuplePattern{WalkList(node.Elems, w), node.NodeID}
+	case *StructPattern:
+		retVal = &StructPattern{node.Name, node.Fields, WalkList(node.Patterns, w), node.NodeID}
+	case *TypePattern:
+		retVal = &TypePattern{WalkAst(node.Bind, w).(*Ident), node.Type, node.NodeID}
 	case *StrExp:
 		retVal = node
 	case *BoolExp:
@@ -123,6 +181,10 @@ func WalkAst(astNode Node, w AstWalker) Node {
 		retVal = node
 	case *FlowControl:
 		retVal = node
+	case *Import:
+		retVal = node
+	case *Module:
+		retVal = &Module{node.Name, node.Path, WalkBlock(node.Body, w), node.NodeID}
 	default:
 		panic("WalkAst not defined for type: " + reflect.TypeOf(astNode).String())
 	}
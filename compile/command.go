@@ -0,0 +1,17 @@
+package compile
+
+import (
+	"dandelion/ast"
+	"dandelion/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// compileCommand hands the evaluated program name and arguments to the runtime, which runs the
+// program and returns everything it wrote to stdout
+func (c *Compiler) compileCommand(node *ast.CommandExp) value.Value {
+	argList := &ast.ArrayLiteral{len(node.Args), node.Args, -1, ast.NoID}
+	c.SetType(argList, types.ArrayType{types.StringType{}})
+
+	args := c.CompileNode(argList)
+	return c.currBlock.NewCall(RunCmd, args)
+}
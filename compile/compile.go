@@ -60,6 +60,11 @@ type Compiler struct {
 	onContinue *ir.Block
 	typeTable  TypeTable
 	bailBlock  bool
+	printers   map[types.TypeHash]*ir.Func
+	printLits  map[string]*ir.Global // The constant strings that print writes, by text
+	vtables    VTables
+	tries      int // The number of try blocks around the code being compiled in the current function
+	loopTries  int // The number of those try blocks that are around the innermost loop
 }
 
 type CFunc struct {
@@ -75,13 +80,15 @@ var StrType lltypes.Type = lltypes.NewStruct(lltypes.I64, lltypes.I8Ptr)
 var AnyType lltypes.Type = lltypes.NewStruct(lltypes.I32, lltypes.I8Ptr, IntType)
 var CoroType lltypes.Type = lltypes.NewStruct(lltypes.I1, lltypes.I8Ptr)
 var LenType lltypes.Type
-var CapType = lltypes.I32
-var IntType = lltypes.I32
+var CapType = lltypes.I64
+var IntType = lltypes.I64
 var ByteType = lltypes.I8
 var BoolType = lltypes.I1
-var FloatType = lltypes.Float
-var Zero = constant.NewInt(IntType, 0)
-var One = constant.NewInt(IntType, 1)
+var FloatType = lltypes.Double
+
+// Zero and One are i32 since LLVM only accepts i32 constants as struct field indices
+var Zero = constant.NewInt(lltypes.I32, 0)
+var One = constant.NewInt(lltypes.I32, 1)
 
 func (c *Compiler) getLabel(label string) string {
 	c.LabelNo++
@@ -115,18 +122,23 @@ func (c *Compiler) llType(myType types.Type) lltypes.Type {
 		return lltypes.NewPointer(lltypes.NewStruct(LenType, CapType, arrPtr))
 	case types.CoroutineType:
 		return lltypes.I8Ptr
+	case types.MapType:
+		// Maps are opaque handles to the runtime hash table
+		return lltypes.I8Ptr
 	case types.StructType:
-		typeDef, ok := c.TypeDefs[t.Name]
+		typeDef, ok := c.TypeDefs[t.TypeString()]
 		if ok {
 			return typeDef
 		}
 
-		structDef := c.prog.Struct(t.Name)
+		structDef := c.prog.Struct(t.TypeString())
 		memberTypes := make([]lltypes.Type, len(structDef.Members))
 		for i, member := range structDef.Members {
 			memberTypes[i] = c.llType(member.Type)
 		}
 		return lltypes.NewPointer(lltypes.NewStruct(memberTypes...))
+	case types.EnumType:
+		return c.TypeDefs[t.Name]
 	case types.TupleType:
 		elemTypes := make([]lltypes.Type, len(t.Types))
 		for i, elem := range t.Types {
@@ -136,14 +148,24 @@ func (c *Compiler) llType(myType types.Type) lltypes.Type {
 		return lltypes.NewPointer(lltypes.NewStruct(elemTypes...))
 	case types.AnyType:
 		return lltypes.NewPointer(AnyType)
+	case types.InterfaceType:
+		return lltypes.NewPointer(InterfaceValueType)
 	default:
 		panic(fmt.Sprintf("Unknown type: %v", reflect.TypeOf(myType)))
 	}
 }
 
+// PromiseType is the struct shared between a coroutine and its caller. It holds the last
+// yielded value and the value most recently sent into the coroutine.
 func (c *Compiler) PromiseType(coroutineType types.CoroutineType) *lltypes.StructType {
 	return lltypes.NewStruct(
-		c.llType(coroutineType.Yields), lltypes.I32)
+		c.llType(coroutineType.Yields), lltypes.I32, c.llType(coroutineType.Reads))
+}
+
+// coroPromise returns a typed pointer to the promise of the given coroutine handle
+func (c *Compiler) coroPromise(handle value.Value, coroType types.CoroutineType) value.Value {
+	voidPromise := c.currBlock.NewCall(CoroPromise, handle, constant.NewInt(lltypes.I32, 4), constant.False)
+	return c.currBlock.NewBitCast(voidPromise, lltypes.NewPointer(c.PromiseType(coroType)))
 }
 
 func (c *Compiler) Type(node ast.Node) types.Type {
@@ -156,15 +178,36 @@ func (c *Compiler) SetType(node ast.Node, ty types.Type) {
 
 func (c *Compiler) SetupTypes(prog *ast.Program) {
 	StrType = c.mod.NewTypeDef("str", StrType)
-	LenType = c.mod.NewTypeDef("len_t", lltypes.NewInt(32))
+	LenType = c.mod.NewTypeDef("len_t", lltypes.NewInt(64))
+
+	// Enums are a variant tag and a pointer to the variant's payload, like any values.
+	// They're set up first because struct members can be enums.
+	for i := 0; i < prog.EnumCount(); i++ {
+		enumDef := prog.EnumNo(i)
+		enumType := lltypes.NewStruct(lltypes.I32, lltypes.I8Ptr)
+		c.TypeDefs[enumDef.Type.Name] = lltypes.NewPointer(c.mod.NewTypeDef(enumDef.Type.Name, enumType))
+	}
 
+	// Generic structs aren't types themselves. Each of their instances, like Stack[int], is set up like any
+	// other struct. All of the structs are named first, since an instance can have instances after it as members.
+	structTypes := make([]*lltypes.StructType, prog.StructCount())
 	for i := 0; i < prog.StructCount(); i++ {
 		structDef := prog.StructNo(i)
-		structType := lltypes.NewStruct()
-		c.TypeDefs[structDef.Type.Name] = lltypes.NewPointer(c.mod.NewTypeDef(structDef.Type.Name, structType))
+		if structDef.Generic() {
+			continue
+		}
+		structName := structDef.Type.TypeString()
+		structTypes[i] = lltypes.NewStruct()
+		c.TypeDefs[structName] = lltypes.NewPointer(c.mod.NewTypeDef(structName, structTypes[i]))
+	}
 
+	for i := 0; i < prog.StructCount(); i++ {
+		structDef := prog.StructNo(i)
+		if structDef.Generic() {
+			continue
+		}
 		for _, member := range structDef.Members {
-			structType.Fields = append(structType.Fields, c.llType(member.Type))
+			structTypes[i].Fields = append(structTypes[i].Fields, c.llType(member.Type))
 		}
 	}
 }
@@ -172,19 +215,22 @@ func (c *Compiler) SetupTypes(prog *ast.Program) {
 func (c *Compiler) CompileLoopBody(block *ast.Block, onBreak *ir.Block, onContinue *ir.Block) {
 	currBreak := c.onBreak
 	currContinue := c.onContinue
+	currLoopTries := c.loopTries
 	c.onBreak = onBreak
 	c.onContinue = onContinue
+	c.loopTries = c.tries
 
 	c.CompileBlock(block)
 
 	c.onBreak = currBreak
 	c.onContinue = currContinue
+	c.loopTries = currLoopTries
 }
 
 func (c *Compiler) SetupFuncs(prog *ast.Program) {
 	c.setupIntrinsics()
 
-	abs := c.mod.NewFunc("abs", lltypes.I32, ir.NewParam("x", lltypes.I32))
+	abs := c.mod.NewFunc("llabs", IntType, ir.NewParam("x", IntType))
 	c.FEnv["abs"] = &CFunc{abs, nil, nil, nil}
 
 	for name, fun := range prog.Funcs {
@@ -204,6 +250,11 @@ func (c *Compiler) SetupFuncs(prog *ast.Program) {
 			params = append(params, newParam)
 		}
 
+		if name == "main" {
+			// main gets the program's args, which are saved for the args builtin
+			params = append(params, ir.NewParam("argc", lltypes.I32), ir.NewParam("argv", lltypes.NewPointer(lltypes.I8Ptr)))
+		}
+
 		funPtr := c.mod.NewFunc(name, llRetType, params...)
 		c.FEnv[name] = &CFunc{funPtr, nil, nil, make(map[*ir.Block]bool)}
 	}
@@ -216,6 +267,7 @@ func (c *Compiler) CompileFunc(name string, fun *ast.FunDef) {
 	}
 	c.currFun = cFun.Func
 	c.currBlock = c.currFun.NewBlock("entry")
+	c.tries = 0
 	funType := c.Type(fun).(types.FuncType)
 	if *fun.IsCoro {
 		c.currBlock = c.SetupCoro(c.currBlock, c.currFun, funType.RetType.(types.CoroutineType))
@@ -262,6 +314,7 @@ func (c *Compiler) CompileFunc(name string, fun *ast.FunDef) {
 
 	if name == "main" {
 		c.currBlock.NewStore(constant.NewInt(IntType, 0), cFun.RetPtr)
+		c.currBlock.NewCall(SetArgs, c.currFun.Params[0], c.currFun.Params[1])
 	}
 	for lineNo, line := range fun.Body.Lines {
 		lastVal := c.CompileNode(line)
@@ -294,6 +347,9 @@ func Compile(prog *ast.Program, Types map[ast.NodeHash]types.Type) string {
 	c.PEnv = make(PointerEnv)
 	c.FEnv = make(map[string]*CFunc)
 	c.TypeDefs = make(map[string]lltypes.Type)
+	c.printers = make(map[types.TypeHash]*ir.Func)
+	c.printLits = make(map[string]*ir.Global)
+	c.vtables = make(VTables)
 	c.Types = Types
 	c.prog = prog
 
@@ -316,6 +372,7 @@ func Compile(prog *ast.Program, Types map[ast.NodeHash]types.Type) string {
 	// Reorder allocas
 	for _, fun := range c.mod.Funcs {
 		c.reorderAllocas(fun)
+		volatileLocals(fun)
 	}
 	return c.mod.String()
 }
@@ -428,6 +485,39 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		compLeft := c.CompileNode(node.Left)
 		compRight := c.CompileNode(node.Right)
 		retVal = c.currBlock.NewICmp(node.LLPred(), compLeft, compRight)
+	case *ast.BitOp:
+		compLeft := c.CompileNode(node.Left)
+		compRight := c.CompileNode(node.Right)
+		if node.IsShift() {
+			compRight = c.castInt(compRight, compLeft.Type().(*lltypes.IntType))
+		}
+
+		switch node.Op {
+		case "&":
+			retVal = c.currBlock.NewAnd(compLeft, compRight)
+		case "|":
+			retVal = c.currBlock.NewOr(compLeft, compRight)
+		case "^":
+			retVal = c.currBlock.NewXor(compLeft, compRight)
+		case "<<":
+			retVal = c.currBlock.NewShl(compLeft, compRight)
+		case ">>":
+			if compLeft.Type().Equal(ByteType) {
+				// Bytes are unsigned, don't sign extend them
+				retVal = c.currBlock.NewLShr(compLeft, compRight)
+			} else {
+				retVal = c.currBlock.NewAShr(compLeft, compRight)
+			}
+		}
+	case *ast.Complement:
+		target := c.CompileNode(node.Target)
+		allOnes := constant.NewInt(target.Type().(*lltypes.IntType), -1)
+		retVal = c.currBlock.NewXor(target, allOnes)
+	case *ast.AndOr:
+		retVal = c.compileAndOr(node)
+	case *ast.Not:
+		target := c.CompileNode(node.Target)
+		retVal = c.currBlock.NewXor(target, constant.True)
 	case *ast.ReturnExp:
 		cFun := c.FEnv[c.currFun.Name()]
 
@@ -439,6 +529,7 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		cFun.RetBlocks[c.currBlock] = true
 		storeVal := c.CompileNode(node.Target)
 		c.currBlock.NewStore(storeVal, cFun.RetPtr)
+		c.leaveTries(c.tries)
 		c.currBlock.NewBr(cFun.RetBlock)
 		c.bailBlock = true
 	case *ast.YieldExp:
@@ -458,6 +549,8 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 			ir.NewCase(constant.NewInt(lltypes.I8, 1), c.currCoro.Cleanup))
 
 		c.currBlock = resumeBlock
+		readPtr := NewGetElementPtr(c.currBlock, c.currCoro.Promise, Zero, constant.NewInt(lltypes.I32, 2))
+		retVal = NewLoad(c.currBlock, readPtr)
 	case *ast.Closure:
 		tuplePtr := c.CompileNode(node.ArgTup)
 		sourceFuncPtr := c.CompileNode(node.Target)
@@ -465,21 +558,7 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 
 		retVal = c.extractFirstArg(c.currBlock, sourceFuncPtr, tuplePtr, newFunType)
 	case *ast.If:
-		prevContinuation := c.currBlock.Term
-
-		cond := c.CompileNode(node.Cond)
-
-		ifBody := c.currFun.NewBlock(c.getLabel("ifbody"))
-		postIf := c.currFun.NewBlock(c.getLabel("postif"))
-		ifBody.NewBr(postIf)
-		postIf.Term = prevContinuation
-
-		c.currBlock.NewCondBr(cond, ifBody, postIf)
-
-		c.currBlock = ifBody
-		c.CompileBlock(node.Body)
-
-		c.currBlock = postIf
+		retVal = c.compileIf(node)
 	case *ast.While:
 		prevContinuation := c.currBlock.Term
 
@@ -500,6 +579,10 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		c.CompileLoopBody(node.Body, postWhile, whileCondBlock)
 
 		c.currBlock = postWhile
+	case *ast.TryCatch:
+		c.compileTryCatch(node)
+	case *ast.Throw:
+		c.currBlock.NewCall(ThrowError, c.CompileNode(node.Value))
 	case *ast.For:
 		prevContinuation := c.currBlock.Term
 
@@ -532,6 +615,12 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		var compNode ast.Node
 
 		iterType := c.Type(node.Iter)
+		_, isMap := iterType.(types.MapType)
+		if isMap {
+			c.compileMapIter(node)
+			break
+		}
+
 		compNode, typeMap := parser.DesugarForIter(node.Body, node.Iter, node.Item, iterType)
 		for newNode, newType := range typeMap {
 			c.SetType(newNode, newType)
@@ -554,6 +643,7 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		}
 
 		cFun.RetBlocks[c.currBlock] = true
+		c.leaveTries(c.tries - c.loopTries)
 		if node.Type == ast.FlowBreak {
 			c.currBlock.NewBr(c.onBreak)
 		} else if node.Type == ast.FlowContinue {
@@ -569,7 +659,8 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 
 		c.CompileBlock(node.Block)
 	case *ast.StrExp:
-		strPtr := c.currBlock.NewAlloca(StrType)
+		// Strings can be returned from the function that creates them, so they can't live on its stack
+		strPtr := MallocType(c.currBlock, StrType)
 
 		constArr := c.mod.NewGlobalDef(c.getLabel("strconst"), constant.NewCharArrayFromString(node.Value))
 
@@ -579,7 +670,7 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 
 		// Store actual string pointer
 		charPtr := NewGetElementPtr(c.currBlock, constArr, Zero, Zero)
-		charPtrDest := NewGetElementPtr(c.currBlock, strPtr, Zero, constant.NewInt(IntType, 1))
+		charPtrDest := NewGetElementPtr(c.currBlock, strPtr, Zero, One)
 		c.currBlock.NewStore(charPtr, charPtrDest)
 		retVal = strPtr
 	case *ast.ArrayLiteral:
@@ -594,7 +685,7 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 
 		// Set list cap
 		minCap := int64(math.Max(float64(node.Length), 8))
-		capVal := constant.NewInt(lltypes.I32, minCap)
+		capVal := constant.NewInt(CapType, minCap)
 		c.setArrCap(list, capVal)
 
 		// Get array start ptr
@@ -623,8 +714,11 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		_, isTup := targType.(types.TupleType)
 		_, isList := targType.(types.ArrayType)
 		_, isStr := targType.(types.StringType)
+		mapType, isMap := targType.(types.MapType)
 
-		if isList {
+		if isMap {
+			retVal = NewLoad(c.currBlock, c.mapSlot(sliceable, index, mapType, MapGet))
+		} else if isList {
 			// Setup bounds check
 			len := c.arrLen(sliceable)
 			c.setupBoundsCheck(len, index)
@@ -632,7 +726,7 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 			elemPtr := c.getListElemPtr(sliceable, index)
 			retVal = NewLoad(c.currBlock, elemPtr)
 		} else if isTup || transform.IsCloArg(node.Arr) {
-			elemPtr := NewGetElementPtr(c.currBlock, sliceable, Zero, index)
+			elemPtr := NewGetElementPtr(c.currBlock, sliceable, Zero, fieldIndex(index))
 			retVal = NewLoad(c.currBlock, elemPtr)
 		} else if isStr {
 			dataPtrPtr := NewGetElementPtr(c.currBlock, sliceable, Zero, One)
@@ -642,9 +736,19 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		} else {
 			panic("Unknown slice target: " + node.Arr.String())
 		}
+	case *ast.RangeSlice:
+		retVal = c.compileRangeSlice(node)
+	case *ast.CommandExp:
+		retVal = c.compileCommand(node)
+	case *ast.InterpStr:
+		retVal = c.compileInterp(node)
+	case *ast.MapLiteral:
+		retVal = c.compileMapLiteral(node)
+	case *ast.InExp:
+		retVal = c.compileIn(node)
 	case *ast.TupleAccess:
 		tup := c.CompileNode(node.Tup)
-		index := constant.NewInt(IntType, int64(node.Index))
+		index := constant.NewInt(lltypes.I32, int64(node.Index))
 
 		elemPtr := NewGetElementPtr(c.currBlock, tup, Zero, index)
 		retVal = NewLoad(c.currBlock, elemPtr)
@@ -692,19 +796,7 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		c.currBlock.NewCondBr(areEq, contBlock, failBlock)
 
 		c.currBlock = contBlock
-
-		// Actually convert the value to the correct type
-		var valPtr value.Value
-		targetLLType := c.llType(node.TargetType)
-		_, isPtr := targetLLType.(*lltypes.PointerType)
-		if isPtr {
-			valPtr = NewGetElementPtr(c.currBlock, compTarg, Zero, constant.NewInt(lltypes.I32, 1))
-		} else {
-			valPtr = NewGetElementPtr(c.currBlock, compTarg, Zero, constant.NewInt(lltypes.I32, 2))
-		}
-
-		sourcePtr := c.currBlock.NewBitCast(valPtr, lltypes.NewPointer(targetLLType))
-		retVal = c.currBlock.NewLoad(targetLLType, sourcePtr)
+		retVal = c.anyValue(compTarg, node.TargetType)
 	case *ast.IsExp:
 		checkTypeNo := c.typeTable.GetNo(node.CheckType)
 		checkNodeType := c.Type(node.CheckNode)
@@ -735,7 +827,19 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		}
 
 		retVal = structPtr
+	case *ast.EnumInstance:
+		retVal = c.compileEnumInstance(node)
+	case *ast.InterfaceValue:
+		retVal = c.compileInterfaceValue(node)
+	case *ast.Match:
+		retVal = c.compileMatch(node)
 	case *ast.StructAccess:
+		ifaceType, isIface := c.Type(node.Target).(types.InterfaceType)
+		if isIface {
+			retVal = c.interfaceMethod(node, ifaceType)
+			break
+		}
+
 		structPtr := c.CompileNode(node.Target)
 		structType, isStructType := c.Type(node.Target).(types.StructType)
 		if !isStructType {
@@ -743,14 +847,7 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 			errs.CheckExit()
 		}
 
-		var structDef *ast.StructDef
-		for i := 0; i < c.prog.StructCount(); i++ {
-			s := c.prog.StructNo(i)
-			if s.Type.Name == structType.Name {
-				structDef = s
-				break
-			}
-		}
+		structDef := c.prog.Struct(structType.TypeString())
 
 		method := structDef.Method(node.Field.(*ast.Ident).Value)
 		if method != nil {
@@ -762,10 +859,15 @@ func (c *Compiler) CompileNode(astNode ast.Node) value.Value {
 		} else {
 			// Member handling
 			structOffset := structDef.Offset(node.Field.(*ast.Ident).Value)
-			memberPtr := NewGetElementPtr(c.currBlock, structPtr, Zero, constant.NewInt(IntType, int64(structOffset)))
+			memberPtr := NewGetElementPtr(c.currBlock, structPtr, Zero, constant.NewInt(lltypes.I32, int64(structOffset)))
 			retVal = NewLoad(c.currBlock, memberPtr)
 		}
 	case *ast.Extern:
+		// A program can declare an extern that the prelude already declares
+		_, declared := c.FEnv[node.Name]
+		if declared {
+			break
+		}
 		funcType, isFuncType := node.Type.(types.FuncType)
 
 		if isFuncType {
@@ -797,11 +899,24 @@ func (c *Compiler) compileBuiltin(node *ast.BuiltinExp) value.Value {
 	case ast.BuiltinNext:
 		coroType := c.Type(node.Args[0]).(types.CoroutineType)
 		targetCoro := c.CompileNode(node.Args[0])
+		promiseStruct := c.coroPromise(targetCoro, coroType)
+
+		// Resuming without a send delivers the zero value
+		readPtr := NewGetElementPtr(c.currBlock, promiseStruct, Zero, constant.NewInt(lltypes.I32, 2))
+		c.currBlock.NewStore(constant.NewZeroInitializer(c.llType(coroType.Reads)), readPtr)
+
 		c.currBlock.NewCall(CoroResume, targetCoro)
-		voidPromise := c.currBlock.NewCall(CoroPromise, targetCoro, constant.NewInt(lltypes.I32, 4), constant.False)
-		promiseStruct := c.currBlock.NewBitCast(voidPromise, lltypes.NewPointer(c.PromiseType(coroType)))
 		yieldPtr := NewGetElementPtr(c.currBlock, promiseStruct, Zero, Zero)
 		retVal = NewLoad(c.currBlock, yieldPtr)
+	case ast.BuiltinSend:
+		coroType := c.Type(node.Args[0]).(types.CoroutineType)
+		targetCoro := c.CompileNode(node.Args[0])
+		sendVal := c.CompileNode(node.Args[1])
+		promiseStruct := c.coroPromise(targetCoro, coroType)
+
+		readPtr := NewGetElementPtr(c.currBlock, promiseStruct, Zero, constant.NewInt(lltypes.I32, 2))
+		c.currBlock.NewStore(sendVal, readPtr)
+		c.currBlock.NewCall(CoroResume, targetCoro)
 	case ast.BuiltinAny:
 		target := node.Args[0]
 		compTarget := c.CompileNode(target)
@@ -825,20 +940,40 @@ func (c *Compiler) compileBuiltin(node *ast.BuiltinExp) value.Value {
 			compTarget = c.currBlock.NewBitCast(compTarget, lltypes.I8Ptr)
 		} else {
 			valStorePtr = NewGetElementPtr(c.currBlock, anyPtr, Zero, constant.NewInt(lltypes.I32, 2))
+			valStorePtr = c.currBlock.NewBitCast(valStorePtr, lltypes.NewPointer(compTarget.Type()))
 		}
 		c.currBlock.NewStore(compTarget, valStorePtr)
 
 		retVal = anyPtr
 	case ast.BuiltinType:
 		retVal = constant.NewInt(IntType, int64(c.typeTable.GetNo(c.Type(node))))
+	case ast.BuiltinExitCode:
+		retVal = c.currBlock.NewCall(CmdStatus)
+	case ast.BuiltinArgv:
+		retVal = c.currBlock.NewCall(GetArgs)
+	case ast.BuiltinEnv:
+		retVal = c.currBlock.NewCall(GetEnv, c.CompileNode(node.Args[0]))
 	case ast.BuiltinDone:
 		handle := c.CompileNode(node.Args[0])
 		retVal = c.currBlock.NewCall(CoroDone, handle)
 	case ast.BuiltinStr:
+		if _, isArr := c.Type(node.Args[0]).(types.ArrayType); !isArr {
+			retVal = c.formatValue(c.CompileNode(node.Args[0]), c.Type(node.Args[0]))
+			break
+		}
+
 		byteArr := c.CompileNode(node.Args[0])
 		len := c.arrLen(byteArr)
 		dataPtr := c.arrData(byteArr)
 		retVal = c.createString(len, dataPtr)
+	case ast.BuiltinInt, ast.BuiltinFloat, ast.BuiltinByte:
+		retVal = c.compileConvert(node)
+	case ast.BuiltinParseInt:
+		retVal = c.compileParse(node, ParseInt)
+	case ast.BuiltinParseFloat:
+		retVal = c.compileParse(node, ParseFloat)
+	case ast.BuiltinPrint:
+		retVal = c.compilePrint(node)
 	default:
 		panic("No compilation step defined for builtin " + node.Type)
 	}
@@ -891,9 +1026,11 @@ func (c *Compiler) compileLen(node *ast.BuiltinExp) value.Value {
 		retVal = constant.NewInt(IntType, int64(len(ty.Types)))
 	case types.StringType:
 		targetString := c.CompileNode(node.Args[0])
-		lenPtr := NewGetElementPtr(c.currBlock, targetString, constant.NewInt(IntType, 0), constant.NewInt(IntType, 0))
-		sizeVal := c.currBlock.NewLoad(lltypes.I64, lenPtr)
-		retVal = c.currBlock.NewTrunc(sizeVal, lltypes.I32)
+		lenPtr := NewGetElementPtr(c.currBlock, targetString, Zero, Zero)
+		retVal = NewLoad(c.currBlock, lenPtr)
+	case types.MapType:
+		targetMap := c.CompileNode(node.Args[0])
+		retVal = c.currBlock.NewCall(MapLen, targetMap)
 	default:
 		panic("builtin function len not applicable to type " + reflect.TypeOf(targetType).String())
 	}
@@ -923,6 +1060,11 @@ func NewGetElementPtr(block *ir.Block, src value.Value, indicies ...value.Value)
 	return block.NewGetElementPtr(src.Type().(*lltypes.PointerType).ElemType, src, indicies...)
 }
 
+// fieldIndex converts a constant index into the i32 that LLVM requires for indexing struct fields
+func fieldIndex(index value.Value) value.Value {
+	return constant.NewInt(lltypes.I32, index.(*constant.Int).X.Int64())
+}
+
 func GetSize(block *ir.Block, typ lltypes.Type) value.Value {
 	sizePtr := NewGetElementPtr(block, constant.NewNull(lltypes.NewPointer(typ)), constant.NewInt(lltypes.I32, 1))
 	size := block.NewPtrToInt(sizePtr, lltypes.I64)
@@ -961,30 +1103,7 @@ func (c *Compiler) compileAssign(node *ast.Assign) value.Value {
 
 	switch target := node.Target.(type) {
 	case *ast.Ident:
-		targetName := target.Value
-		targetAddr, ok := c.PEnv.Get(c.currFun.Name(), targetName)
-		if !ok {
-			targetType, ok := c.Types[ast.HashNode(node.Target)]
-			if !ok {
-				panic("Identifier not in type environment: " + targetName)
-			}
-			targetLLType := c.llType(targetType)
-
-			ptr, isPtr := targetLLType.(*lltypes.PointerType)
-			isFunc := false
-			if isPtr {
-				_, isFunc = ptr.ElemType.(*lltypes.FuncType)
-			}
-			if isPtr && !isFunc {
-				targetAddr = MallocType(c.currBlock, targetLLType)
-			} else {
-				targetAddr = c.currBlock.NewAlloca(targetLLType)
-			}
-
-			targetAddr.(value.Named).SetName(targetName)
-			c.PEnv.Set(c.currFun.Name(), targetName, targetAddr)
-		}
-
+		targetAddr := c.identAddr(target)
 		compiledExpr := c.CompileNode(node.Expr)
 		c.currBlock.NewStore(compiledExpr, targetAddr)
 	case *ast.SliceNode:
@@ -995,8 +1114,9 @@ func (c *Compiler) compileAssign(node *ast.Assign) value.Value {
 		arrType := c.Type(target.Arr)
 		_, isTup := arrType.(types.TupleType)
 		_, isArr := arrType.(types.ArrayType)
+		mapType, isMap := arrType.(types.MapType)
 		if isTup {
-			elemPtr = NewGetElementPtr(c.currBlock, list, Zero, index)
+			elemPtr = NewGetElementPtr(c.currBlock, list, Zero, fieldIndex(index))
 			retVal = NewLoad(c.currBlock, elemPtr)
 		} else if isArr {
 			// Setup bounds check
@@ -1004,6 +1124,8 @@ func (c *Compiler) compileAssign(node *ast.Assign) value.Value {
 			c.setupBoundsCheck(len, index)
 
 			elemPtr = c.getListElemPtr(list, index)
+		} else if isMap {
+			elemPtr = c.mapSlot(list, index, mapType, MapSet)
 		}
 
 		srcPtr := c.CompileNode(node.Expr)
@@ -1017,7 +1139,7 @@ func (c *Compiler) compileAssign(node *ast.Assign) value.Value {
 		_, isTup := arrType.(types.TupleType)
 		_, isArr := arrType.(types.ArrayType)
 		if isTup {
-			elemPtr = NewGetElementPtr(c.currBlock, list, Zero, index)
+			elemPtr = NewGetElementPtr(c.currBlock, list, Zero, fieldIndex(index))
 			retVal = NewLoad(c.currBlock, elemPtr)
 		} else if isArr {
 			// Setup bounds check
@@ -1034,7 +1156,7 @@ func (c *Compiler) compileAssign(node *ast.Assign) value.Value {
 		expPtr := c.CompileNode(node.Expr)
 
 		structType := c.Type(target.Target).(types.StructType)
-		structDef := c.prog.Struct(structType.Name)
+		structDef := c.prog.Struct(structType.TypeString())
 		structOffset := structDef.Offset(target.Field.(*ast.Ident).Value)
 		destPtr := NewGetElementPtr(c.currBlock, structPtr, Zero, constant.NewInt(lltypes.I32, int64(structOffset)))
 		c.currBlock.NewStore(expPtr, destPtr)
@@ -1043,6 +1165,148 @@ func (c *Compiler) compileAssign(node *ast.Assign) value.Value {
 	return retVal
 }
 
+// identAddr returns the storage for a variable, allocating it the first time the variable is assigned
+// anyValue unwraps the value of type ty held by an any value
+func (c *Compiler) anyValue(anyVal value.Value, ty types.Type) value.Value {
+	var valPtr value.Value
+	targetLLType := c.llType(ty)
+	_, isPtr := targetLLType.(*lltypes.PointerType)
+	if isPtr {
+		valPtr = NewGetElementPtr(c.currBlock, anyVal, Zero, constant.NewInt(lltypes.I32, 1))
+	} else {
+		valPtr = NewGetElementPtr(c.currBlock, anyVal, Zero, constant.NewInt(lltypes.I32, 2))
+	}
+
+	sourcePtr := c.currBlock.NewBitCast(valPtr, lltypes.NewPointer(targetLLType))
+	return c.currBlock.NewLoad(targetLLType, sourcePtr)
+}
+
+func (c *Compiler) identAddr(target *ast.Ident) value.Value {
+	targetName := target.Value
+	targetAddr, ok := c.PEnv.Get(c.currFun.Name(), targetName)
+	if ok {
+		return targetAddr
+	}
+
+	targetType, ok := c.Types[ast.HashNode(target)]
+	if !ok {
+		panic("Identifier not in type environment: " + targetName)
+	}
+	targetLLType := c.llType(targetType)
+
+	ptr, isPtr := targetLLType.(*lltypes.PointerType)
+	isFunc := false
+	if isPtr {
+		_, isFunc = ptr.ElemType.(*lltypes.FuncType)
+	}
+	if isPtr && !isFunc {
+		targetAddr = MallocType(c.currBlock, targetLLType)
+	} else {
+		targetAddr = c.currBlock.NewAlloca(targetLLType)
+	}
+
+	targetAddr.(value.Named).SetName(targetName)
+	c.PEnv.Set(c.currFun.Name(), targetName, targetAddr)
+
+	return targetAddr
+}
+
+func (c *Compiler) compileIf(node *ast.If) value.Value {
+	prevContinuation := c.currBlock.Term
+
+	// If the if is used as an expression, each branch stores its value here
+	var resPtr value.Value
+	ifType := c.Type(node)
+	_, isVoid := ifType.(types.VoidType)
+	if node.HasValue() && !isVoid {
+		resPtr = c.currBlock.NewAlloca(c.llType(ifType))
+	}
+
+	cond := c.CompileNode(node.Cond)
+
+	ifBody := c.currFun.NewBlock(c.getLabel("ifbody"))
+	postIf := c.currFun.NewBlock(c.getLabel("postif"))
+	ifBody.NewBr(postIf)
+	postIf.Term = prevContinuation
+
+	elseBody := postIf
+	if node.Else != nil {
+		elseBody = c.currFun.NewBlock(c.getLabel("elsebody"))
+		elseBody.NewBr(postIf)
+	}
+
+	c.currBlock.NewCondBr(cond, ifBody, elseBody)
+
+	c.currBlock = ifBody
+	c.compileBranch(node.Body, resPtr)
+
+	if node.Else != nil {
+		c.currBlock = elseBody
+		c.compileBranch(node.Else, resPtr)
+	}
+
+	c.currBlock = postIf
+	if resPtr != nil {
+		return NewLoad(c.currBlock, resPtr)
+	}
+
+	return nil
+}
+
+// castInt truncates or zero extends an integer value to the given width
+func (c *Compiler) castInt(val value.Value, destType *lltypes.IntType) value.Value {
+	srcType := val.Type().(*lltypes.IntType)
+	if srcType.BitSize > destType.BitSize {
+		return c.currBlock.NewTrunc(val, destType)
+	} else if srcType.BitSize < destType.BitSize {
+		return c.currBlock.NewZExt(val, destType)
+	}
+
+	return val
+}
+
+// compileAndOr only evaluates the right operand when the left one doesn't already decide the result
+func (c *Compiler) compileAndOr(node *ast.AndOr) value.Value {
+	resPtr := c.currBlock.NewAlloca(BoolType)
+	left := c.CompileNode(node.Left)
+	c.currBlock.NewStore(left, resPtr)
+
+	rightBlock := c.currFun.NewBlock(c.getLabel("logicright"))
+	postLogic := c.currFun.NewBlock(c.getLabel("postlogic"))
+	rightBlock.NewBr(postLogic)
+	postLogic.Term = c.currBlock.Term
+
+	if node.Op == "&&" {
+		c.currBlock.NewCondBr(left, rightBlock, postLogic)
+	} else {
+		c.currBlock.NewCondBr(left, postLogic, rightBlock)
+	}
+
+	c.currBlock = rightBlock
+	right := c.CompileNode(node.Right)
+	c.currBlock.NewStore(right, resPtr)
+
+	c.currBlock = postLogic
+	return NewLoad(c.currBlock, resPtr)
+}
+
+// compileBranch compiles a block and stores the value of its last line in resPtr, unless the block
+// was left early by a return or loop control statement.
+func (c *Compiler) compileBranch(block *ast.Block, resPtr value.Value) {
+	var lastVal value.Value
+	for _, line := range block.Lines {
+		lastVal = c.CompileNode(line)
+		if c.bailBlock {
+			c.bailBlock = false
+			return
+		}
+	}
+
+	if resPtr != nil && lastVal != nil {
+		c.currBlock.NewStore(lastVal, resPtr)
+	}
+}
+
 func (c *Compiler) setupBoundsCheck(len value.Value, index value.Value) {
 	contBlock := c.currFun.NewBlock(c.getLabel("slicecont"))
 	contBlock.Term = c.currBlock.Term
@@ -1057,6 +1321,34 @@ func (c *Compiler) setupBoundsCheck(len value.Value, index value.Value) {
 	c.currBlock = contBlock
 }
 
+func (c *Compiler) compileRangeSlice(node *ast.RangeSlice) value.Value {
+	sliceable := c.CompileNode(node.Arr)
+	_, isStr := c.Type(node.Arr).(types.StringType)
+
+	var low value.Value = constant.NewInt(IntType, 0)
+	if node.Low != nil {
+		low = c.CompileNode(node.Low)
+	}
+
+	var high value.Value
+	if node.High != nil {
+		high = c.CompileNode(node.High)
+	} else if isStr {
+		high = NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, sliceable, Zero, Zero))
+	} else {
+		high = c.arrLen(sliceable)
+	}
+
+	// The runtime checks the bounds before slicing
+	if isStr {
+		return c.currBlock.NewCall(StrSlice, sliceable, low, high)
+	}
+
+	voidList := c.currBlock.NewBitCast(sliceable, lltypes.I8Ptr)
+	sliced := c.currBlock.NewCall(ListSlice, voidList, low, high, c.listElemSize(node.Arr))
+	return c.currBlock.NewBitCast(sliced, sliceable.Type())
+}
+
 func (c *Compiler) getListElemPtr(list value.Value, index value.Value) value.Value {
 	// Load the pointer to the array from the struct
 	arrStart := c.arrData(list)
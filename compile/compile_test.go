@@ -2,6 +2,9 @@ package compile
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
@@ -1837,6 +1840,140 @@ print(chr)
 	}
 }
 
+func TestElifChain(t *testing.T) {
+	src := `
+grade = f(score) {
+	g = 0
+	if score > 90 {
+		g = 4
+	} elif score > 80 {
+		g = 3
+	} elif score > 70 {
+		g = 2
+	}
+	else {
+		g = 1
+	}
+	g
+}
+
+return grade(95) * 1000 + grade(85) * 100 + grade(75) * 10 + grade(10)
+`
+
+	if !CompileCheckExit(src, 4321) {
+		t.Fail()
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	src := `
+sign = f(x) {
+	if x < 0 { -1; } elif x == 0 { 0; } else { 1; }
+}
+
+extern prints: f(string)void
+name = if sign(7) == 1 { "positive"; } else { "negative"; }
+prints(name)
+return sign(-5) + sign(0) + sign(3) + 10
+`
+
+	if !CompileCheckOutput(src, "positive") {
+		t.Fail()
+	}
+	if !CompileCheckExit(src, 10) {
+		t.Fail()
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	src := `
+extern print: f(int)void
+
+check = f(n, res) {
+	print(n)
+	res
+}
+
+if check(1, false) && check(2, true) {
+	print(10)
+}
+if check(3, true) || check(4, false) {
+	print(20)
+}
+if !check(5, false) && (check(6, false) || check(7, true)) {
+	print(30)
+}
+`
+
+	if !CompileCheckOutput(src, "1\n3\n20\n5\n6\n7\n30") {
+		t.Fail()
+	}
+}
+
+func TestNotCall(t *testing.T) {
+	src := `
+extern print: f(int)void
+
+isodd = f(n: int) bool { n % 2 == 1 }
+double = f(n: int) int { n * 2 }
+flags = [true, false]
+
+if !isodd(4) {
+	print(1)
+}
+if !isodd(3) {
+	print(2)
+}
+if !flags[1] {
+	print(3)
+}
+print(3 * double(2))
+`
+
+	if !CompileCheckOutput(src, "1\n3\n12") {
+		t.Fail()
+	}
+}
+
+func TestBitwiseOps(t *testing.T) {
+	src := `
+extern print: f(int)void
+
+print(12 & 10)
+print(12 | 3)
+print(12 ^ 10)
+print(1 << 4)
+print(-16 >> 2)
+print(~5)
+print(1 << 2 | 1)
+
+upper = 'a' ^ ' '
+high = '\n' << 4
+if upper == 'A' && high >> 4 == '\n' {
+	print(1)
+}
+`
+
+	if !CompileCheckOutput(src, "8\n15\n6\n16\n-4\n-6\n5\n1") {
+		t.Fail()
+	}
+}
+
+func TestComplementCall(t *testing.T) {
+	src := `
+extern print: f(int)void
+
+mask = f(n: int) int { n & 12 }
+masks = [3, 5]
+print(~mask(7))
+print(~masks[1])
+`
+
+	if !CompileCheckOutput(src, "-5\n-6") {
+		t.Fail()
+	}
+}
+
 //func TestMutableNumClosure(t *testing.T) {
 //	src := `
 //x = 22;
@@ -1853,3 +1990,808 @@ print(chr)
 //		t.Fail()
 //	}
 //}
+
+func TestMapOps(t *testing.T) {
+	src := `
+extern print: f(int)void
+extern prints: f(string)void
+
+counts = {"apple": 1, "pear": 2}
+counts["plum"] = 3
+counts["apple"] = counts["apple"] + 10
+counts.delete("pear")
+
+total = 0
+for (k, v) in counts {
+	total = total + v
+}
+print(total)
+print(len(counts))
+
+if "plum" in counts {
+	prints("has plum")
+}
+if !("pear" in counts) {
+	prints("no pear")
+}
+
+squares = {:}
+for i = 0; i < 20; i = i + 1 {
+	squares[i] = i * i
+}
+print(squares[19])
+print(len(squares))
+`
+
+	if !CompileCheckOutput(src, "14\n2\nhas plum\nno pear\n361\n20") {
+		t.Fail()
+	}
+}
+
+func TestCommandExp(t *testing.T) {
+	src := `
+extern print: f(int)void
+extern prints: f(string)void
+
+name = "dandelion"
+out = ` + "`printf '%s-%s' {name} \"two words\"`" + `
+prints(out)
+print(exitcode())
+` + "`sh -c 'exit 3'`" + `
+print(exitcode())
+`
+
+	if !CompileCheckOutput(src, "dandelion-two words\n0\n3") {
+		t.Fail()
+	}
+}
+
+func TestStringInterp(t *testing.T) {
+	src := `
+extern prints: f(string)void
+
+n = 42
+fname = "main.dlx"
+prints("found {n} matches in {fname}")
+prints("{2.5} {n > 40} {'z'} {n + 1} \{literal\}")
+prints(` + "`echo n={n}`" + `)
+`
+
+	if !CompileCheckOutput(src, "found 42 matches in main.dlx\n2.5 true z 43 {literal}\nn=42\n") {
+		t.Fail()
+	}
+}
+
+func TestCoroSend(t *testing.T) {
+	src := `
+extern print: f(int)void
+
+acc = f() {
+	total = 0
+	while true {
+		x = yield total
+		total = total + x
+	}
+}
+
+c = acc()
+print(next(c))
+send(c, 5)
+send(c, 10)
+print(next(c))
+send(c, 7)
+print(next(c))
+`
+
+	if !CompileCheckOutput(src, "0\n15\n22") {
+		t.Fail()
+	}
+}
+
+func TestListMethods(t *testing.T) {
+	src := `
+extern print: f(int)void
+
+l = [1, 2, 3]
+print(l.pop())
+l.insert(0, 9)
+l.insert(3, 7)
+l.extend([4, 5])
+print(len(l))
+print(l.remove(1))
+l.reverse()
+print(l[0])
+print(l.index(7))
+print(l.index(100))
+
+words = ["a", "bc"]
+print(words.index("b" + "c"))
+words.clear()
+print(len(words))
+`
+
+	if !CompileCheckOutput(src, "3\n6\n1\n5\n2\n-1\n1\n0") {
+		t.Fail()
+	}
+}
+
+func TestRangeSlice(t *testing.T) {
+	src := `
+extern print: f(int)void
+extern prints: f(string)void
+
+s = "hello world"
+prints(s[0:5])
+prints(s[6:])
+prints(s[:4])
+
+l = [1, 2, 3, 4]
+m = l[1:3]
+m.push(10)
+print(m[2])
+print(len(m))
+print(l[2])
+print(len(l[4:]))
+`
+
+	if !CompileCheckOutput(src, "hello\nworld\nhell\n10\n3\n3\n0") {
+		t.Fail()
+	}
+}
+
+func TestRangeSliceOOB(t *testing.T) {
+	src := `
+l = [1, 2, 3]
+x = l[2:5]
+`
+
+	if !CompileCheckExit(src, 2) {
+		t.Fail()
+	}
+}
+
+func TestStringMethods(t *testing.T) {
+	src := `
+extern print: f(int)void
+extern prints: f(string)void
+
+t = "  a,b,,c  ".trim()
+prints(t)
+parts = t.split(",")
+print(len(parts))
+prints("-".join(parts))
+print(len(" one  two three ".split("")))
+print(t.find(",,"))
+if t.contains("b,") && !t.contains("q") {
+	prints("contains")
+}
+prints(t.replace(",", " | "))
+if t.startswith("a,") && !t.endswith("b") {
+	prints("affixes")
+}
+prints("Hello".upper().repeat(2))
+prints("HeLLo".lower())
+`
+
+	if !CompileCheckOutput(src, "a,b,,c\n4\na-b--c\n3\n3\ncontains\na | b |  | c\naffixes\nHELLOHELLO\nhello") {
+		t.Fail()
+	}
+}
+
+func TestComments(t *testing.T) {
+	src := `
+extern print: f(int)void
+
+# Doubles a number
+double = f(x) {
+	x * 2 // trailing comment
+}
+
+/* print(100)
+   print(200) */
+print(double(4)) # after a call
+print(/* inline */ 3)
+`
+
+	if !CompileCheckOutput(src, "8\n3") {
+		t.Fail()
+	}
+}
+
+func TestMultilineStatements(t *testing.T) {
+	src := `
+extern print: f(int)void
+
+add = f(a, b) { a + b }
+
+total = add(
+	1,
+	2
+)
+print(total)
+
+nums = [
+	10,
+	20,
+	30
+]
+nums
+	-> f{ e + 3 }
+	-> f{ print(e) }
+
+sum = nums[0] +
+	nums[1]
+print(sum)
+`
+
+	if !CompileCheckOutput(src, "3\n13\n23\n33\n30") {
+		t.Fail()
+	}
+}
+
+func TestWideNumbers(t *testing.T) {
+	src := `
+extern print: f(int)void
+extern prints: f(string)void
+
+big = 3000000000 * 3
+print(big)
+print(abs(-5000000000))
+
+nums = [1, 2, 9000000000]
+print(nums[2] - big)
+
+x = 0.1 + 0.2
+prints("{x}")
+`
+
+	if !CompileCheckOutput(src, "9000000000\n5000000000\n0\n0.30000000000000004") {
+		t.Fail()
+	}
+}
+
+func TestConversions(t *testing.T) {
+	src := `
+extern print: f(int)void
+extern prints: f(string)void
+
+print(int(3.9))
+print(int(" 42\n"))
+print(int('a'))
+print(int(true))
+prints(str(float(7)))
+prints(str(float("2.5")))
+prints(str(byte(65)))
+print(int(byte(300)))
+prints(str(12) + str(false))
+
+n = parseint("12x")
+print(n.0)
+prints(str(n.1))
+x = parsefloat("1e3")
+prints("{x.0} {x.1}")
+`
+
+	if !CompileCheckOutput(src, "3\n42\n97\n1\n7\n2.5\nA\n44\n12false\n0\nfalse\n1000 true") {
+		t.Fail()
+	}
+}
+
+func TestConversionFail(t *testing.T) {
+	src := `
+x = int("nope")
+`
+
+	if !CompileCheckExit(src, 2) {
+		t.Fail()
+	}
+}
+
+func TestPrint(t *testing.T) {
+	src := `
+struct Point {
+	x: int
+	tags: []string
+}
+
+print(1, "two", 3.5, true, 'c')
+print([1, 2, 3], ["a", "b"])
+print((1, "x", 'z'))
+print({"k": [1.5]})
+print(Point(4, ["t"]))
+print(any(7))
+print()
+`
+
+	if !CompileCheckOutput(src, "1 two 3.5 true c\n[1, 2, 3] [\"a\", \"b\"]\n(1, \"x\", 'z')\n{\"k\": [1.5]}\nPoint{x: 4, tags: [\"t\"]}\n7\n") {
+		t.Fail()
+	}
+}
+
+func TestPrintShadowed(t *testing.T) {
+	src := `
+print = f(x) {
+	x
+}
+
+res = print(5)
+return res
+`
+
+	if !CompileCheckExit(src, 5) {
+		t.Fail()
+	}
+}
+
+func TestEnumMatch(t *testing.T) {
+	src := `
+enum Shape {
+	Circle(float)
+	Rect(float, float)
+	Empty
+}
+
+area = f(s: Shape) float {
+	match s {
+		Circle(r) => 3.0 * r * r
+		Rect(w, h) => w * h
+		Empty => 0.0
+	}
+}
+
+print(area(Circle(1.0)), area(Rect(2.0, 3.0)), area(Empty))
+print(Rect(1.5, 2.0), Empty)
+
+describe = f(s: Shape) string {
+	match s {
+		Circle(_) => "round"
+		other => "other"
+	}
+}
+print(describe(Circle(1.0)), describe(Empty))
+`
+
+	if !CompileCheckOutput(src, "3 6 0\nRect(1.5, 2) Empty\nround other\n") {
+		t.Fail()
+	}
+}
+
+func TestEnumRecursive(t *testing.T) {
+	src := `
+enum List {
+	Cons(int, List)
+	Nil
+}
+
+sum = f(l: List) int {
+	match l {
+		Cons(h, t) => h + sum(t)
+		Nil => 0
+	}
+}
+
+return sum(Cons(1, Cons(2, Cons(3, Nil))))
+`
+
+	if !CompileCheckExit(src, 6) {
+		t.Fail()
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	src := `
+struct Point {
+	x: int
+	y: int
+}
+
+pair = f(t: (int, string)) string {
+	match t {
+		(0, s) => s
+		(n, "x") if n > 0 => "positive x"
+		(-1, _) => "minus one"
+		_ => "other"
+	}
+}
+print(pair((0, "zero")), pair((3, "x")), pair((-3, "x")), pair((-1, "y")))
+
+pt = f(p: Point) int {
+	match p {
+		Point{x: 0, y: y} => y
+		Point{y: 0} => -1
+		Point{x: x} => x
+	}
+}
+print(pt(Point(0, 5)), pt(Point(3, 0)), pt(Point(7, 2)))
+
+flag = match false {
+	true => "yes"
+	false => "no"
+}
+print(flag)
+`
+
+	if !CompileCheckOutput(src, "zero positive x other minus one\n5 -1 7\nno\n") {
+		t.Fail()
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	src := `
+classify = f(v: any) int {
+	match v {
+		n: int if n > 100 => 100
+		n: int => n
+		s: string => len(s)
+		_ => 0
+	}
+}
+
+return classify(any(5)) + classify(any(500)) + classify(any("abc")) + classify(any(1.5))
+`
+
+	if !CompileCheckExit(src, 108) {
+		t.Fail()
+	}
+}
+
+func TestLetPolymorphism(t *testing.T) {
+	src := `
+ident = f(x) { x }
+mapList = f(xs, g) {
+	out = []
+	for i = 0; i < len(xs); i = i + 1 {
+		out.push(g(xs[i]))
+	}
+	out
+}
+
+n = 10
+print(ident(5), ident("five"))
+print(mapList([1, 2, 3], f(x) { x + n }))
+print(mapList(["a", "b"], f(s) { s + "!" }))
+print(mapList([1, 2], f(x) { x > 1 }))
+`
+
+	if !CompileCheckOutput(src, "5 five\n[11, 12, 13]\n[\"a!\", \"b!\"]\n[false, true]\n") {
+		t.Fail()
+	}
+}
+
+func TestGenericInstances(t *testing.T) {
+	src := `
+pair = f(a, b) { (b, a) }
+first = f(t) { t.0 }
+count = f(xs) { len(xs) }
+ident = f(x) { x }
+apply = f(g, x) { g(x) }
+
+print(pair(1, "one"), pair("two", 2), pair(3, "three"))
+print(first((1, 2)), first(("a", 1)))
+print(count([1, 2, 3]), count(["a"]), count([[1], [2]]))
+print(apply(ident, 1), apply(ident, "s"))
+`
+
+	if !CompileCheckOutput(src, "(\"one\", 1) (2, \"two\") (\"three\", 3)\n1 a\n3 1 2\n1 s\n") {
+		t.Fail()
+	}
+}
+
+func TestGenericStruct(t *testing.T) {
+	src := `
+struct Stack[T] {
+	items: []T
+}
+
+Stack.push = f(x) {
+	items.push(x)
+}
+
+Stack.top = f() {
+	items[len(items) - 1]
+}
+
+struct Pair[A, B] {
+	first: A
+	second: B
+}
+
+describe = f(p: Pair[string, int]) string {
+	"{p.first}={p.second}"
+}
+
+s = Stack([1, 2])
+s.push(3)
+words = Stack(["a"])
+words.push("b")
+print(s.top(), words.top(), words)
+print(describe(Pair("x", 5)))
+
+match s {
+	Stack{items: xs} => print(len(xs))
+}
+`
+
+	if !CompileCheckOutput(src, "3 b Stack[string]{items: [\"a\", \"b\"]}\nx=5\n3\n") {
+		t.Fail()
+	}
+}
+
+func TestInterface(t *testing.T) {
+	src := `
+interface Named {
+	name: f() string
+	legs: f(int) int
+}
+
+struct Dog {
+	title: string
+}
+
+struct Bird {
+	wings: int
+}
+
+Dog.name = f() { title }
+Dog.legs = f(extra) { 4 + extra }
+Bird.name = f() { "bird" }
+Bird.legs = f(extra) { wings + extra }
+
+greet = f(n: Named) string {
+	"hello " + n.name()
+}
+
+pets = [Named(Dog("rex")), Named(Bird(2))]
+print(greet(pets[1]), pets[0].legs(1))
+print(pets -> f{ e.name() })
+print(pets)
+`
+
+	if !CompileCheckOutput(src, "hello bird 5\n[\"rex\", \"bird\"]\n[Dog{title: \"rex\"}, Bird{wings: 2}]\n") {
+		t.Fail()
+	}
+}
+
+func TestPipelineElemMethod(t *testing.T) {
+	src := `
+struct Dog {
+	title: string
+}
+
+Dog.name = f() { title }
+
+pets = [Dog("rex"), Dog("fido")]
+print(pets -> f{ e.name() })
+`
+
+	if !CompileCheckOutput(src, "[\"rex\", \"fido\"]\n") {
+		t.Fail()
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	src := `
+parse = f(s: string) int {
+	try {
+		return int(s)
+	} catch e {
+		print(e)
+		return -1
+	}
+}
+
+nums = [1, 2, 3]
+n = 0
+try {
+	n = 7
+	print(nums[5])
+} catch e {
+	print(e, n)
+}
+
+try {
+	try {
+		throw "inner"
+	} catch e {
+		throw "outer " + e
+	}
+} catch e {
+	print(e)
+}
+
+v = any(5)
+try {
+	print(v.(string))
+} catch e {
+	print(e)
+}
+
+print(parse("1") + parse("x") + parse("3"))
+print(nums[10])
+`
+
+	if !CompileCheckOutput(src, "index 5 out of bounds 7\nouter inner\ninvalid assertion from any type\ncan't convert \"x\" to int\n3\nFatal error: index 10 out of bounds\n") {
+		t.Fail()
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dandelion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	modules := map[string]string{
+		"util.dan": `
+import "strs"
+
+count = 3
+label = f(s) { strs.join(["util", s]) }
+`,
+		"strs/join.dan": `
+join = f(parts) {
+	res = ""
+	for i = 0; i < len(parts); i = i + 1 {
+		if i > 0 {
+			res = res + _sep
+		}
+		res = res + parts[i]
+	}
+	res
+}
+`,
+		"strs/consts.dan": `
+_sep = "-"
+`,
+	}
+	os.Mkdir(filepath.Join(dir, "strs"), os.ModePerm)
+	for name, src := range modules {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(src), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := fmt.Sprintf(`
+import "%s" as u
+import "%s"
+
+count = 10
+label = f(s) { "main " + s }
+print(u.label("x"), label("y"), u.count, count, strs.join(["a", "b"]))
+`, filepath.Join(dir, "util.dan"), filepath.Join(dir, "strs"))
+
+	if !CompileCheckOutput(src, "util-x main y 3 10 a-b\n") {
+		t.Fail()
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	src := `
+count = 2
+min = f(l) { l[0] }
+print(math.min(3, 5), math.max(9, 4), math.clamp(15, 0, 10), math.pow(2, 10), math.gcd(12, 18), count, min([7]))
+print(collections.sum(collections.range(5)), collections.count([1, 2, 1], 1), strings.isdigit('7'), strings.isspace('x'))
+print(strings.padleft("ab", 4) + "|", strings.padright("ab", 4) + "|")
+print(collections.keys({"a": 1}), collections.values({"a": 1}))
+`
+	if !CompileCheckOutput(src, "3 9 10 1024 6 2 7\n10 2 true false\n  ab| ab  |\n[\"a\"] [1]\n") {
+		t.Fail()
+	}
+}
+
+func TestPreludeShadowed(t *testing.T) {
+	src := `
+math = struct {
+	min: int;
+}(4)
+print(math.min)
+strings = ["a", "b"]
+print(strings[1])
+`
+	if !CompileCheckOutput(src, "4\nb\n") {
+		t.Fail()
+	}
+}
+
+func TestArgsEnv(t *testing.T) {
+	os.Setenv("DANDELION_TEST", "hello")
+	src := `
+print(len(args()), env("DANDELION_TEST"), len(env("DANDELION_UNSET")))
+env = f(name) { name + "!" }
+print(env("x"))
+`
+	if !CompileCheckOutput(src, "0 hello 0\nx!\n") {
+		t.Fail()
+	}
+}
+
+func TestFileIO(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dandelion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := fmt.Sprintf(`
+path = "%s"
+io.writefile(path, "one\ntwo\n")
+io.appendfile(path, "three")
+for line in io.lines(path) {
+	print(line)
+}
+
+file = io.open(path, "r+")
+print(io.readline(file))
+io.seek(file, 0, io.seekend)
+io.write(file, "!")
+print(io.seek(file, 0, io.seekstart))
+print(io.read(file), io.eof(file))
+io.close(file)
+close = "closed"
+print(close)
+
+info = io.stat(path)
+print(info.0, info.2, io.stat("%s").2)
+
+try {
+	io.open("%s", "r")
+} catch e {
+	print(e)
+}
+try {
+	io.close(file)
+} catch e {
+	print(e)
+}
+try {
+	io.open(path, "rw")
+} catch e {
+	print(e)
+}
+`, filepath.Join(dir, "f.txt"), dir, filepath.Join(dir, "missing"))
+
+	expected := fmt.Sprintf(`one
+two
+three
+one
+0
+one
+two
+three! true
+closed
+14 false true
+can't open '%s': No such file or directory
+can't use file: Bad file descriptor
+invalid file mode 'rw'
+`, filepath.Join(dir, "missing"))
+	if !CompileCheckOutput(src, expected) {
+		t.Fail()
+	}
+}
+
+func TestStatementIf(t *testing.T) {
+	src := `
+x = 0
+i = 0
+while i < 2 {
+	if i == 0 {
+		x = 1
+		5
+	} else {
+		print("no")
+	}
+	i = i + 1
+}
+if x == 1 { "a" } else { 3 }
+
+pick = f(c) { if c { 1 } else { 2 } }
+y = if x == 1 { 10 } else { 20 }
+print(x, y, pick(false))
+`
+
+	if !CompileCheckOutput(src, "no\n1 10 2\n") {
+		t.Fail()
+	}
+}
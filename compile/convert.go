@@ -0,0 +1,57 @@
+package compile
+
+import (
+	"dandelion/ast"
+	"dandelion/types"
+	lltypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// compileConvert converts a primitive value for the int, float and byte builtins
+func (c *Compiler) compileConvert(node *ast.BuiltinExp) value.Value {
+	val := c.CompileNode(node.Args[0])
+	srcType := c.Type(node.Args[0])
+	_, toFloat := c.Type(node).(types.FloatType)
+
+	// Strings are parsed, failing at runtime if they don't hold a number
+	if _, isStr := srcType.(types.StringType); isStr {
+		if toFloat {
+			return c.currBlock.NewCall(StrFloat, val)
+		}
+		val = c.currBlock.NewCall(StrInt, val)
+		srcType = types.IntType{}
+	}
+
+	switch srcType.(type) {
+	case types.FloatType:
+		if toFloat {
+			return val
+		}
+		val = c.currBlock.NewFPToSI(val, IntType)
+	case types.IntType:
+		if toFloat {
+			return c.currBlock.NewSIToFP(val, FloatType)
+		}
+	default:
+		// Bytes and bools are unsigned
+		if toFloat {
+			return c.currBlock.NewUIToFP(val, FloatType)
+		}
+	}
+
+	return c.castInt(val, c.llType(c.Type(node)).(*lltypes.IntType))
+}
+
+// compileParse parses a string into a tuple of the value and whether parsing succeeded
+func (c *Compiler) compileParse(node *ast.BuiltinExp, parseFunc value.Value) value.Value {
+	str := c.CompileNode(node.Args[0])
+	okPtr := c.currBlock.NewAlloca(lltypes.I32)
+	parsed := c.currBlock.NewCall(parseFunc, str, okPtr)
+
+	tupleType := c.llType(c.Type(node)).(*lltypes.PointerType).ElemType
+	tuple := MallocType(c.currBlock, tupleType)
+	c.currBlock.NewStore(parsed, NewGetElementPtr(c.currBlock, tuple, Zero, Zero))
+	c.currBlock.NewStore(c.runtimeBool(NewLoad(c.currBlock, okPtr)), NewGetElementPtr(c.currBlock, tuple, Zero, One))
+
+	return tuple
+}
@@ -0,0 +1,41 @@
+package compile
+
+import (
+	"dandelion/ast"
+
+	"github.com/llir/llvm/ir/constant"
+	lltypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// payloadType is the struct holding the values carried by a variant
+func (c *Compiler) payloadType(variant *ast.EnumVariant) lltypes.Type {
+	fieldTypes := make([]lltypes.Type, len(variant.Types))
+	for i, ty := range variant.Types {
+		fieldTypes[i] = c.llType(ty)
+	}
+
+	return lltypes.NewStruct(fieldTypes...)
+}
+
+func (c *Compiler) compileEnumInstance(node *ast.EnumInstance) value.Value {
+	enumType := c.llType(node.DefRef.Type).(*lltypes.PointerType).ElemType
+	enumPtr := MallocType(c.currBlock, enumType)
+
+	tag := constant.NewInt(lltypes.I32, int64(node.DefRef.Tag(node.Variant)))
+	c.currBlock.NewStore(tag, NewGetElementPtr(c.currBlock, enumPtr, Zero, Zero))
+
+	// Variants without values don't have a payload
+	var payload value.Value = constant.NewNull(lltypes.I8Ptr)
+	if len(node.Values) > 0 {
+		payloadPtr := MallocType(c.currBlock, c.payloadType(node.DefRef.Variant(node.Variant)))
+		for i, val := range node.Values {
+			fieldPtr := NewGetElementPtr(c.currBlock, payloadPtr, Zero, constant.NewInt(lltypes.I32, int64(i)))
+			c.currBlock.NewStore(c.CompileNode(val), fieldPtr)
+		}
+		payload = c.currBlock.NewBitCast(payloadPtr, lltypes.I8Ptr)
+	}
+	c.currBlock.NewStore(payload, NewGetElementPtr(c.currBlock, enumPtr, Zero, One))
+
+	return enumPtr
+}
@@ -0,0 +1,86 @@
+package compile
+
+import (
+	"dandelion/ast"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	lltypes "github.com/llir/llvm/ir/types"
+)
+
+// compileTryCatch pushes an error handler and runs the try block. Errors thrown while the handler is
+// active jump back to the setjmp call, which then returns nonzero and runs the catch block instead.
+func (c *Compiler) compileTryCatch(node *ast.TryCatch) {
+	prevContinuation := c.currBlock.Term
+
+	env := c.currBlock.NewCall(TryPush)
+	jumped := c.currBlock.NewCall(SetJmp, env)
+
+	postTry := c.currFun.NewBlock(c.getLabel("posttry"))
+	postTry.Term = prevContinuation
+
+	// The handler is only popped here if the try block finishes, throwing pops it in the runtime
+	tryEnd := c.currFun.NewBlock(c.getLabel("tryend"))
+	tryEnd.NewCall(TryPop)
+	tryEnd.NewBr(postTry)
+
+	tryBlock := c.currFun.NewBlock(c.getLabel("try"))
+	tryBlock.NewBr(tryEnd)
+	catchBlock := c.currFun.NewBlock(c.getLabel("catch"))
+	catchBlock.NewBr(postTry)
+
+	noError := c.currBlock.NewICmp(enum.IPredEQ, jumped, constant.NewInt(lltypes.I32, 0))
+	c.currBlock.NewCondBr(noError, tryBlock, catchBlock)
+
+	c.currBlock = tryBlock
+	c.tries++
+	c.CompileBlock(node.Body)
+	c.tries--
+
+	c.currBlock = catchBlock
+	c.currBlock.NewStore(c.currBlock.NewCall(CatchError), c.identAddr(node.Err))
+	c.CompileBlock(node.Catch)
+
+	c.currBlock = postTry
+}
+
+// leaveTries pops the handlers of try blocks that a return, break or continue jumps out of
+func (c *Compiler) leaveTries(count int) {
+	for i := 0; i < count; i++ {
+		c.currBlock.NewCall(TryPop)
+	}
+}
+
+// volatileLocals makes every load and store in a function with a try block volatile. Variables can
+// change between setjmp returning the first time and returning again after an error, so they can't
+// be kept in registers.
+func volatileLocals(fun *ir.Func) {
+	if !callsSetJmp(fun) {
+		return
+	}
+
+	for _, block := range fun.Blocks {
+		for _, inst := range block.Insts {
+			switch memInst := inst.(type) {
+			case *ir.InstLoad:
+				memInst.Volatile = true
+			case *ir.InstStore:
+				memInst.Volatile = true
+			}
+		}
+	}
+}
+
+func callsSetJmp(fun *ir.Func) bool {
+	for _, block := range fun.Blocks {
+		for _, inst := range block.Insts {
+			call, isCall := inst.(*ir.InstCall)
+			if isCall && call.Callee == SetJmp {
+				return true
+			}
+		}
+	}
+
+	return false
+}
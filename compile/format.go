@@ -0,0 +1,69 @@
+package compile
+
+import (
+	"dandelion/ast"
+	"dandelion/types"
+	"github.com/llir/llvm/ir/constant"
+	lltypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func (c *Compiler) compileInterp(node *ast.InterpStr) value.Value {
+	strs := make([]value.Value, 0)
+	for _, part := range node.Parts {
+		strs = append(strs, c.formatValue(c.CompileNode(part), c.Type(part)))
+	}
+
+	return c.concatStrs(strs)
+}
+
+// formatValue converts a value of any formattable type into a string
+func (c *Compiler) formatValue(val value.Value, valType types.Type) value.Value {
+	switch valType.(type) {
+	case types.StringType:
+		return val
+	case types.IntType:
+		return c.currBlock.NewCall(FmtInt, val)
+	case types.FloatType:
+		return c.currBlock.NewCall(FmtFloat, val)
+	case types.BoolType:
+		return c.currBlock.NewCall(FmtBool, val)
+	case types.ByteType:
+		return c.currBlock.NewCall(FmtByte, val)
+	default:
+		panic("Can't format value of type " + valType.TypeString())
+	}
+}
+
+// concatStrs joins any number of strings with a single allocation
+func (c *Compiler) concatStrs(strs []value.Value) value.Value {
+	lens := make([]value.Value, 0)
+	var newLen value.Value = constant.NewInt(lltypes.I64, 0)
+	for _, str := range strs {
+		strLen := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, str, Zero, Zero))
+		lens = append(lens, strLen)
+		newLen = c.currBlock.NewAdd(newLen, strLen)
+	}
+
+	strSize := GetSize(c.currBlock, StrType)
+	totalLen := c.currBlock.NewAdd(newLen, strSize)
+
+	newStrMem := c.currBlock.NewCall(Malloc, totalLen)
+	newStr := c.currBlock.NewBitCast(newStrMem, lltypes.NewPointer(StrType))
+	c.currBlock.NewStore(newLen, NewGetElementPtr(c.currBlock, newStr, Zero, Zero))
+
+	// The data is stored directly after the string header
+	newStrDataPtr := NewGetElementPtr(c.currBlock, newStr, One)
+	newStrDataPtr = c.currBlock.NewBitCast(newStrDataPtr, lltypes.I8Ptr)
+	c.currBlock.NewStore(newStrDataPtr, NewGetElementPtr(c.currBlock, newStr, Zero, One))
+
+	var offset value.Value = constant.NewInt(lltypes.I64, 0)
+	for k, str := range strs {
+		data := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, str, Zero, One))
+		dest := NewGetElementPtr(c.currBlock, newStrDataPtr, offset)
+		c.currBlock.NewCall(MemCopy, dest, data, lens[k], constant.False)
+		offset = c.currBlock.NewAdd(offset, lens[k])
+	}
+
+	return newStr
+}
@@ -0,0 +1,127 @@
+package compile
+
+import (
+	"dandelion/ast"
+	"dandelion/types"
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	lltypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// InterfaceValueType is a pointer to the struct's data, and a pointer to the method table for the struct's type
+var InterfaceValueType lltypes.Type = lltypes.NewStruct(lltypes.I8Ptr, lltypes.NewPointer(lltypes.I8Ptr))
+
+// VTables holds the method table of each struct type that's converted to each interface.
+// Like the type table, a table is only created for the types that the program uses.
+type VTables map[string]*ir.Global
+
+func (c *Compiler) compileInterfaceValue(node *ast.InterfaceValue) value.Value {
+	structPtr := c.CompileNode(node.Value)
+	structType := c.Type(node.Value).(types.StructType)
+
+	ifacePtr := MallocType(c.currBlock, InterfaceValueType)
+	dataPtr := NewGetElementPtr(c.currBlock, ifacePtr, Zero, Zero)
+	c.currBlock.NewStore(c.currBlock.NewBitCast(structPtr, lltypes.I8Ptr), dataPtr)
+	vtablePtr := NewGetElementPtr(c.currBlock, ifacePtr, Zero, One)
+	c.currBlock.NewStore(c.vtable(node.Type, structType), vtablePtr)
+
+	return ifacePtr
+}
+
+// vtable returns a pointer to the method table of a struct type for an interface, creating it on first use.
+// It has the struct's implementation of each of the interface's methods in order, followed by the function
+// that prints the struct.
+func (c *Compiler) vtable(ifaceType types.InterfaceType, structType types.StructType) value.Value {
+	name := fmt.Sprintf("vtable.%s.%s", ifaceType.Name, structType.TypeString())
+	table, ok := c.vtables[name]
+	if !ok {
+		ifaceDef := c.prog.Interface(ifaceType.Name)
+		structDef := c.prog.Struct(structType.TypeString())
+
+		entries := make([]constant.Constant, 0)
+		for _, method := range ifaceDef.Methods {
+			targetFun := c.FEnv[structDef.Method(method.Name.Value).TargetName].Func
+			entries = append(entries, constant.NewBitCast(targetFun, lltypes.I8Ptr))
+		}
+		entries = append(entries, constant.NewBitCast(c.printer(structType), lltypes.I8Ptr))
+
+		tableType := lltypes.NewArray(uint64(len(entries)), lltypes.I8Ptr)
+		table = c.mod.NewGlobalDef(name, constant.NewArray(tableType, entries...))
+		table.Immutable = true
+		c.vtables[name] = table
+	}
+
+	return constant.NewGetElementPtr(table.ContentType, table, Zero, Zero)
+}
+
+// vtableEntry loads the function at an index of an interface value's method table
+func vtableEntry(block *ir.Block, ifacePtr value.Value, index int) value.Value {
+	vtable := NewLoad(block, NewGetElementPtr(block, ifacePtr, Zero, One))
+	return NewLoad(block, NewGetElementPtr(block, vtable, constant.NewInt(lltypes.I32, int64(index))))
+}
+
+// interfaceMethod binds a method to an interface value, like accessing the method on a struct directly
+func (c *Compiler) interfaceMethod(node *ast.StructAccess, ifaceType types.InterfaceType) value.Value {
+	ifacePtr := c.CompileNode(node.Target)
+	dispatcher := c.dispatcher(c.prog.Interface(ifaceType.Name), node.Field.(*ast.Ident).Value)
+
+	return c.extractFirstArg(c.currBlock, dispatcher, ifacePtr, c.llType(c.Type(node)))
+}
+
+// dispatcher returns the function that calls a method of the struct in an interface value, creating it on
+// first use. Trampolines can only bind functions that are known when compiling, so the interface value is
+// bound to the dispatcher, which looks up the method in the value's method table.
+func (c *Compiler) dispatcher(ifaceDef *ast.InterfaceDef, methodName string) *ir.Func {
+	name := fmt.Sprintf("%s.dispatch.%s", ifaceDef.Type.Name, methodName)
+	cFun, ok := c.FEnv[name]
+	if ok {
+		return cFun.Func
+	}
+
+	index := ifaceDef.MethodIndex(methodName)
+	methodType := ifaceDef.Methods[index].Type.(types.FuncType)
+	ifaceParam := ir.NewParam("iface", lltypes.NewPointer(InterfaceValueType))
+	ifaceParam.Attrs = append(ifaceParam.Attrs, enum.ParamAttrNest)
+	params := []*ir.Param{ifaceParam}
+	argTypes := []lltypes.Type{lltypes.I8Ptr}
+	for _, argType := range methodType.ArgTypes {
+		params = append(params, ir.NewParam("", c.llType(argType)))
+		argTypes = append(argTypes, c.llType(argType))
+	}
+	retType := c.llType(methodType.RetType)
+	fun := c.mod.NewFunc(name, retType, params...)
+	c.FEnv[name] = &CFunc{fun, nil, nil, nil}
+
+	// The method's first argument is the struct, which it takes as its nest argument too
+	block := fun.NewBlock("entry")
+	data := NewLoad(block, NewGetElementPtr(block, ifaceParam, Zero, Zero))
+	methodPtr := vtableEntry(block, ifaceParam, index)
+	method := block.NewBitCast(methodPtr, lltypes.NewPointer(lltypes.NewFunc(retType, argTypes...)))
+	args := []value.Value{ir.NewArg(data, enum.ParamAttrNest)}
+	for _, param := range params[1:] {
+		args = append(args, param)
+	}
+	res := block.NewCall(method, args...)
+
+	_, isVoid := methodType.RetType.(types.VoidType)
+	if isVoid {
+		block.NewRet(nil)
+	} else {
+		block.NewRet(res)
+	}
+
+	return fun
+}
+
+// printInterface writes the struct in an interface value with the printer in its method table
+func (c *Compiler) printInterface(ifacePtr value.Value, ifaceType types.InterfaceType) {
+	ifaceDef := c.prog.Interface(ifaceType.Name)
+	data := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, ifacePtr, Zero, Zero))
+	printerPtr := vtableEntry(c.currBlock, ifacePtr, len(ifaceDef.Methods))
+	printerType := lltypes.NewPointer(lltypes.NewFunc(lltypes.Void, lltypes.I8Ptr))
+	c.currBlock.NewCall(c.currBlock.NewBitCast(printerPtr, printerType), data)
+}
@@ -17,8 +17,6 @@ var MallocData value.Value
 var GCInit value.Value
 var MemCopy value.Value
 var Free value.Value
-var OpenF value.Value
-var ReadF value.Value
 
 // Coroutine intrinsics
 var CoroID value.Value
@@ -39,6 +37,78 @@ var PrintS value.Value
 var ThrowEx value.Value
 var IndexError value.Value
 
+// Error handling runtime
+var TryPush value.Value
+var TryPop value.Value
+var CatchError value.Value
+var ThrowError value.Value
+var SetJmp value.Value
+
+// Map runtime
+var MapNew value.Value
+var MapGet value.Value
+var MapSet value.Value
+var MapHas value.Value
+var MapDel value.Value
+var MapLen value.Value
+var MapNext value.Value
+var MapKey value.Value
+var MapVal value.Value
+
+// Command runtime
+var RunCmd value.Value
+var CmdStatus value.Value
+
+// Process runtime
+var SetArgs value.Value
+var GetArgs value.Value
+var GetEnv value.Value
+
+// List runtime
+var ListInsert value.Value
+var ListRemove value.Value
+var ListExtend value.Value
+var ListReverse value.Value
+var ListIndex value.Value
+
+// Slicing runtime
+var StrSlice value.Value
+var ListSlice value.Value
+
+// String runtime
+var StrSplit value.Value
+var StrJoin value.Value
+var StrTrim value.Value
+var StrFind value.Value
+var StrReplace value.Value
+var StrStartsWith value.Value
+var StrEndsWith value.Value
+var StrUpper value.Value
+var StrLower value.Value
+var StrRepeat value.Value
+var StrEq value.Value
+
+// Formatting runtime
+var FmtInt value.Value
+var FmtFloat value.Value
+var FmtBool value.Value
+var FmtByte value.Value
+
+// Print runtime
+var PrintLit value.Value
+var PrintStr value.Value
+var PrintQuoted value.Value
+var PrintInt value.Value
+var PrintFloat value.Value
+var PrintBool value.Value
+var PrintByte value.Value
+
+// Conversion runtime
+var ParseInt value.Value
+var ParseFloat value.Value
+var StrInt value.Value
+var StrFloat value.Value
+
 func (c *Compiler) setupIntrinsics() {
 	PrintB = c.mod.NewFunc(
 		"printb",
@@ -62,7 +132,13 @@ func (c *Compiler) setupIntrinsics() {
 		"alloc_clo",
 		lltypes.I8Ptr)
 	ThrowEx = c.mod.NewFunc("throwex", lltypes.Void, ir.NewParam("exno", lltypes.I32))
-	IndexError = c.mod.NewFunc("indexoob", lltypes.Void, ir.NewParam("index", lltypes.I32))
+	IndexError = c.mod.NewFunc("indexoob", lltypes.Void, ir.NewParam("index", IntType))
+	TryPush = c.mod.NewFunc("try_push", lltypes.I8Ptr)
+	TryPop = c.mod.NewFunc("try_pop", lltypes.Void)
+	CatchError = c.mod.NewFunc("catch_error", lltypes.NewPointer(StrType))
+	ThrowError = c.mod.NewFunc("throw_error", lltypes.Void, ir.NewParam("msg", lltypes.NewPointer(StrType)))
+	SetJmp = c.mod.NewFunc("_setjmp", lltypes.I32, ir.NewParam("env", lltypes.I8Ptr))
+	SetJmp.(*ir.Func).FuncAttrs = append(SetJmp.(*ir.Func).FuncAttrs, enum.FuncAttrReturnsTwice)
 	Malloc = c.mod.NewFunc(
 		"GC_malloc",
 		lltypes.I8Ptr,
@@ -92,15 +168,161 @@ func (c *Compiler) setupIntrinsics() {
 		ir.NewParam("src", lltypes.I8Ptr),
 		ir.NewParam("size", lltypes.I64),
 		ir.NewParam("volatile", lltypes.I1))
-	OpenF = c.mod.NewFunc(
-		"d_open",
+	RunCmd = c.mod.NewFunc(
+		"run_cmd",
+		lltypes.NewPointer(StrType),
+		ir.NewParam("args", c.llType(types.ArrayType{types.StringType{}})))
+	CmdStatus = c.mod.NewFunc(
+		"cmd_status",
+		IntType)
+	SetArgs = c.mod.NewFunc(
+		"set_args",
+		lltypes.Void,
+		ir.NewParam("argc", lltypes.I32),
+		ir.NewParam("argv", lltypes.NewPointer(lltypes.I8Ptr)))
+	GetArgs = c.mod.NewFunc(
+		"get_args",
+		c.llType(types.ArrayType{types.StringType{}}))
+	GetEnv = c.mod.NewFunc(
+		"get_env",
+		lltypes.NewPointer(StrType),
+		ir.NewParam("name", lltypes.NewPointer(StrType)))
+	ListInsert = c.mod.NewFunc(
+		"list_insert",
+		lltypes.I8Ptr,
+		ir.NewParam("list", lltypes.I8Ptr),
+		ir.NewParam("index", IntType),
+		ir.NewParam("elemsize", lltypes.I64))
+	ListRemove = c.mod.NewFunc(
+		"list_remove",
+		lltypes.Void,
+		ir.NewParam("list", lltypes.I8Ptr),
+		ir.NewParam("index", IntType),
+		ir.NewParam("elemsize", lltypes.I64))
+	ListExtend = c.mod.NewFunc(
+		"list_extend",
+		lltypes.Void,
+		ir.NewParam("list", lltypes.I8Ptr),
+		ir.NewParam("other", lltypes.I8Ptr),
+		ir.NewParam("elemsize", lltypes.I64))
+	ListReverse = c.mod.NewFunc(
+		"list_reverse",
+		lltypes.Void,
+		ir.NewParam("list", lltypes.I8Ptr),
+		ir.NewParam("elemsize", lltypes.I64))
+	ListIndex = c.mod.NewFunc(
+		"list_index",
+		IntType,
+		ir.NewParam("list", lltypes.I8Ptr),
+		ir.NewParam("elem", lltypes.I8Ptr),
+		ir.NewParam("elemsize", lltypes.I64),
+		ir.NewParam("kind", lltypes.I32))
+	StrSlice = c.mod.NewFunc(
+		"str_slice",
+		lltypes.NewPointer(StrType),
+		ir.NewParam("str", lltypes.NewPointer(StrType)),
+		ir.NewParam("low", IntType),
+		ir.NewParam("high", IntType))
+	ListSlice = c.mod.NewFunc(
+		"list_slice",
+		lltypes.I8Ptr,
+		ir.NewParam("list", lltypes.I8Ptr),
+		ir.NewParam("low", IntType),
+		ir.NewParam("high", IntType),
+		ir.NewParam("elemsize", lltypes.I64))
+	strPtr := lltypes.NewPointer(StrType)
+	strList := c.llType(types.ArrayType{types.StringType{}})
+	StrSplit = c.mod.NewFunc("str_split", strList, ir.NewParam("str", strPtr), ir.NewParam("sep", strPtr))
+	StrJoin = c.mod.NewFunc("str_join", strPtr, ir.NewParam("sep", strPtr), ir.NewParam("parts", strList))
+	StrTrim = c.mod.NewFunc("str_trim", strPtr, ir.NewParam("str", strPtr))
+	StrFind = c.mod.NewFunc("str_find", IntType, ir.NewParam("str", strPtr), ir.NewParam("sub", strPtr))
+	StrReplace = c.mod.NewFunc(
+		"str_replace",
+		strPtr,
+		ir.NewParam("str", strPtr),
+		ir.NewParam("old", strPtr),
+		ir.NewParam("new", strPtr))
+	StrStartsWith = c.mod.NewFunc("str_startswith", lltypes.I32, ir.NewParam("str", strPtr), ir.NewParam("prefix", strPtr))
+	StrEndsWith = c.mod.NewFunc("str_endswith", lltypes.I32, ir.NewParam("str", strPtr), ir.NewParam("suffix", strPtr))
+	StrUpper = c.mod.NewFunc("str_upper", strPtr, ir.NewParam("str", strPtr))
+	StrLower = c.mod.NewFunc("str_lower", strPtr, ir.NewParam("str", strPtr))
+	StrRepeat = c.mod.NewFunc("str_repeat", strPtr, ir.NewParam("str", strPtr), ir.NewParam("n", IntType))
+	StrEq = c.mod.NewFunc("str_eq", lltypes.I32, ir.NewParam("left", strPtr), ir.NewParam("right", strPtr))
+	FmtInt = c.mod.NewFunc(
+		"fmt_int",
+		lltypes.NewPointer(StrType),
+		ir.NewParam("n", IntType))
+	FmtFloat = c.mod.NewFunc(
+		"fmt_float",
+		lltypes.NewPointer(StrType),
+		ir.NewParam("f", FloatType))
+	FmtBool = c.mod.NewFunc(
+		"fmt_bool",
+		lltypes.NewPointer(StrType),
+		ir.NewParam("b", BoolType))
+	// The runtime takes a C bool, which is a whole byte that the caller must zero extend to
+	FmtBool.(*ir.Func).Params[0].Attrs = append(FmtBool.(*ir.Func).Params[0].Attrs, enum.ParamAttrZeroExt)
+	FmtByte = c.mod.NewFunc(
+		"fmt_byte",
+		lltypes.NewPointer(StrType),
+		ir.NewParam("b", ByteType))
+	PrintLit = c.mod.NewFunc("print_lit", lltypes.Void, ir.NewParam("lit", lltypes.I8Ptr))
+	PrintStr = c.mod.NewFunc("print_str", lltypes.Void, ir.NewParam("str", strPtr))
+	PrintQuoted = c.mod.NewFunc("print_quoted", lltypes.Void, ir.NewParam("str", strPtr))
+	PrintInt = c.mod.NewFunc("print_int", lltypes.Void, ir.NewParam("n", IntType))
+	PrintFloat = c.mod.NewFunc("print_float", lltypes.Void, ir.NewParam("f", FloatType))
+	PrintBool = c.mod.NewFunc("print_bool", lltypes.Void, ir.NewParam("b", BoolType))
+	PrintBool.(*ir.Func).Params[0].Attrs = append(PrintBool.(*ir.Func).Params[0].Attrs, enum.ParamAttrZeroExt)
+	PrintByte = c.mod.NewFunc("print_byte", lltypes.Void, ir.NewParam("b", ByteType))
+	ParseInt = c.mod.NewFunc("parse_int", IntType, ir.NewParam("str", strPtr), ir.NewParam("ok", lltypes.NewPointer(lltypes.I32)))
+	ParseFloat = c.mod.NewFunc("parse_float", FloatType, ir.NewParam("str", strPtr), ir.NewParam("ok", lltypes.NewPointer(lltypes.I32)))
+	StrInt = c.mod.NewFunc("str_int", IntType, ir.NewParam("str", strPtr))
+	StrFloat = c.mod.NewFunc("str_float", FloatType, ir.NewParam("str", strPtr))
+	MapNew = c.mod.NewFunc(
+		"map_new",
+		lltypes.I8Ptr,
+		ir.NewParam("keysize", lltypes.I64),
+		ir.NewParam("valsize", lltypes.I64),
+		ir.NewParam("keykind", lltypes.I32))
+	MapGet = c.mod.NewFunc(
+		"map_get",
+		lltypes.I8Ptr,
+		ir.NewParam("map", lltypes.I8Ptr),
+		ir.NewParam("key", lltypes.I8Ptr))
+	MapSet = c.mod.NewFunc(
+		"map_set",
+		lltypes.I8Ptr,
+		ir.NewParam("map", lltypes.I8Ptr),
+		ir.NewParam("key", lltypes.I8Ptr))
+	MapHas = c.mod.NewFunc(
+		"map_has",
 		lltypes.I32,
-		ir.NewParam("ptr", lltypes.NewPointer(StrType)))
-	ReadF = c.mod.NewFunc(
-		"d_read",
+		ir.NewParam("map", lltypes.I8Ptr),
+		ir.NewParam("key", lltypes.I8Ptr))
+	MapDel = c.mod.NewFunc(
+		"map_del",
+		lltypes.Void,
+		ir.NewParam("map", lltypes.I8Ptr),
+		ir.NewParam("key", lltypes.I8Ptr))
+	MapLen = c.mod.NewFunc(
+		"map_len",
+		IntType,
+		ir.NewParam("map", lltypes.I8Ptr))
+	MapNext = c.mod.NewFunc(
+		"map_next",
 		lltypes.I32,
-		ir.NewParam("fd", lltypes.I32),
-		ir.NewParam("buff", c.llType(types.ArrayType{types.ByteType{}})))
+		ir.NewParam("map", lltypes.I8Ptr),
+		ir.NewParam("slot", lltypes.I32))
+	MapKey = c.mod.NewFunc(
+		"map_key",
+		lltypes.I8Ptr,
+		ir.NewParam("map", lltypes.I8Ptr),
+		ir.NewParam("slot", lltypes.I32))
+	MapVal = c.mod.NewFunc(
+		"map_val",
+		lltypes.I8Ptr,
+		ir.NewParam("map", lltypes.I8Ptr),
+		ir.NewParam("slot", lltypes.I32))
 	CoroID = c.mod.NewFunc(
 		"llvm.coro.id",
 		lltypes.Token,
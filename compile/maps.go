@@ -0,0 +1,113 @@
+package compile
+
+import (
+	"dandelion/ast"
+	"dandelion/types"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	lltypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// The runtime compares most values byte for byte, but strings are compared by their contents
+const (
+	keyRaw = 0
+	keyStr = 1
+)
+
+// keyKind tells the runtime how values of the given type are hashed and compared
+func keyKind(ty types.Type) value.Value {
+	_, isStr := ty.(types.StringType)
+	if isStr {
+		return constant.NewInt(lltypes.I32, keyStr)
+	}
+
+	return constant.NewInt(lltypes.I32, keyRaw)
+}
+
+func (c *Compiler) compileMapLiteral(node *ast.MapLiteral) value.Value {
+	mapType := c.Type(node).(types.MapType)
+
+	keySize := GetSize(c.currBlock, c.llType(mapType.Key))
+	valSize := GetSize(c.currBlock, c.llType(mapType.Value))
+
+	newMap := c.currBlock.NewCall(MapNew, keySize, valSize, keyKind(mapType.Key))
+
+	for k, key := range node.Keys {
+		keyVal := c.CompileNode(key)
+		val := c.CompileNode(node.Values[k])
+		c.currBlock.NewStore(val, c.mapSlot(newMap, keyVal, mapType, MapSet))
+	}
+
+	return newMap
+}
+
+// valueRef stores the value on the stack so it can be handed to the runtime by reference
+func (c *Compiler) valueRef(key value.Value) value.Value {
+	keyPtr := c.currBlock.NewAlloca(key.Type())
+	c.currBlock.NewStore(key, keyPtr)
+
+	return c.currBlock.NewBitCast(keyPtr, lltypes.I8Ptr)
+}
+
+// mapSlot looks up the key with the given runtime function and returns a typed pointer to its value
+func (c *Compiler) mapSlot(mapVal value.Value, key value.Value, mapType types.MapType, lookup value.Value) value.Value {
+	slot := c.currBlock.NewCall(lookup, mapVal, c.valueRef(key))
+	return c.currBlock.NewBitCast(slot, lltypes.NewPointer(c.llType(mapType.Value)))
+}
+
+func (c *Compiler) compileIn(node *ast.InExp) value.Value {
+	container := c.CompileNode(node.Container)
+	item := c.CompileNode(node.Item)
+
+	return c.runtimeBool(c.currBlock.NewCall(MapHas, container, c.valueRef(item)))
+}
+
+func (c *Compiler) mapDelete(mapNode ast.Node, key ast.Node) value.Value {
+	mapVal := c.CompileNode(mapNode)
+	keyVal := c.CompileNode(key)
+	c.currBlock.NewCall(MapDel, mapVal, c.valueRef(keyVal))
+
+	return nil
+}
+
+// compileMapIter walks the occupied slots of the map, binding each key and value before running the body
+func (c *Compiler) compileMapIter(node *ast.ForIter) {
+	prevContinuation := c.currBlock.Term
+
+	mapType := c.Type(node.Iter).(types.MapType)
+	pair := node.Item.(*ast.TupleLiteral)
+	mapVal := c.CompileNode(node.Iter)
+
+	slotPtr := c.currBlock.NewAlloca(lltypes.I32)
+	firstSlot := c.currBlock.NewCall(MapNext, mapVal, constant.NewInt(lltypes.I32, 0))
+	c.currBlock.NewStore(firstSlot, slotPtr)
+
+	mapCond := c.currFun.NewBlock(c.getLabel("mapcond"))
+	c.currBlock.NewBr(mapCond)
+	slot := NewLoad(mapCond, slotPtr)
+	hasNext := mapCond.NewICmp(enum.IPredSGE, slot, constant.NewInt(lltypes.I32, 0))
+
+	mapStep := c.currFun.NewBlock(c.getLabel("mapstep"))
+	nextSlot := mapStep.NewAdd(NewLoad(mapStep, slotPtr), constant.NewInt(lltypes.I32, 1))
+	mapStep.NewStore(mapStep.NewCall(MapNext, mapVal, nextSlot), slotPtr)
+	mapStep.NewBr(mapCond)
+
+	mapBody := c.currFun.NewBlock(c.getLabel("mapbody"))
+	mapBody.NewBr(mapStep)
+	postMap := c.currFun.NewBlock(c.getLabel("postmap"))
+	postMap.Term = prevContinuation
+
+	mapCond.NewCondBr(hasNext, mapBody, postMap)
+
+	c.currBlock = mapBody
+	currSlot := NewLoad(c.currBlock, slotPtr)
+	keyPtr := c.currBlock.NewBitCast(c.currBlock.NewCall(MapKey, mapVal, currSlot), lltypes.NewPointer(c.llType(mapType.Key)))
+	valPtr := c.currBlock.NewBitCast(c.currBlock.NewCall(MapVal, mapVal, currSlot), lltypes.NewPointer(c.llType(mapType.Value)))
+	c.currBlock.NewStore(NewLoad(c.currBlock, keyPtr), c.identAddr(pair.Exprs[0].(*ast.Ident)))
+	c.currBlock.NewStore(NewLoad(c.currBlock, valPtr), c.identAddr(pair.Exprs[1].(*ast.Ident)))
+
+	c.CompileLoopBody(node.Body, postMap, mapStep)
+
+	c.currBlock = postMap
+}
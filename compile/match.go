@@ -0,0 +1,131 @@
+package compile
+
+import (
+	"dandelion/ast"
+	"dandelion/types"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	lltypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// compileMatch tests the arms of a match in order, and runs the body of the first arm that matches the target.
+// Each arm compiles to a chain of tests that branches to the next arm as soon as one of them fails.
+func (c *Compiler) compileMatch(node *ast.Match) value.Value {
+	prevContinuation := c.currBlock.Term
+
+	// If the match is used as an expression, each arm stores its value here
+	var resPtr value.Value
+	matchType := c.Type(node)
+	_, isVoid := matchType.(types.VoidType)
+	if node.HasValue() && !isVoid {
+		resPtr = c.currBlock.NewAlloca(c.llType(matchType))
+	}
+
+	target := c.CompileNode(node.Target)
+	targetType := c.Type(node.Target)
+
+	postMatch := c.currFun.NewBlock(c.getLabel("postmatch"))
+	postMatch.Term = prevContinuation
+
+	for _, arm := range node.Arms {
+		nextArm := c.currFun.NewBlock(c.getLabel("matchnext"))
+		c.matchPattern(arm.Pattern, target, targetType, nextArm)
+		if arm.Guard != nil {
+			c.matchTest(c.CompileNode(arm.Guard), nextArm)
+		}
+
+		armBlock := c.currFun.NewBlock(c.getLabel("matcharm"))
+		armBlock.NewBr(postMatch)
+		c.currBlock.NewBr(armBlock)
+		c.currBlock = armBlock
+		c.compileBranch(arm.Body, resPtr)
+
+		c.currBlock = nextArm
+	}
+
+	// Matches are checked to be exhaustive, so no value gets past the last arm
+	c.currBlock.NewUnreachable()
+
+	c.currBlock = postMatch
+	if resPtr != nil {
+		return NewLoad(c.currBlock, resPtr)
+	}
+
+	return nil
+}
+
+// matchPattern tests val against a pattern, branching to fail if it doesn't match. If it does, the names
+// bound by the pattern are set and compilation continues in the current block.
+func (c *Compiler) matchPattern(pattern ast.Node, val value.Value, valType types.Type, fail *ir.Block) {
+	switch p := pattern.(type) {
+	case *ast.Ident:
+		c.currBlock.NewStore(val, c.identAddr(p))
+	case *ast.Num, *ast.FloatExp, *ast.StrExp, *ast.ByteExp, *ast.BoolExp:
+		c.matchTest(c.valuesEqual(val, c.CompileNode(p), valType), fail)
+	case *ast.TypePattern:
+		_, isAny := p.Type.(types.AnyType)
+		if isAny {
+			c.currBlock.NewStore(val, c.identAddr(p.Bind))
+			break
+		}
+
+		typeTag := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, val, Zero, Zero))
+		typeNo := constant.NewInt(lltypes.I32, int64(c.typeTable.GetNo(p.Type)))
+		c.matchTest(c.currBlock.NewICmp(enum.IPredEQ, typeTag, typeNo), fail)
+		c.currBlock.NewStore(c.anyValue(val, p.Type), c.identAddr(p.Bind))
+	case *ast.VariantPattern:
+		enumDef := c.prog.Enum(valType.(types.EnumType).Name)
+		tag := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, val, Zero, Zero))
+		tagNo := constant.NewInt(lltypes.I32, int64(enumDef.Tag(p.Variant)))
+		c.matchTest(c.currBlock.NewICmp(enum.IPredEQ, tag, tagNo), fail)
+
+		if len(p.Patterns) == 0 {
+			break
+		}
+		variant := enumDef.Variant(p.Variant)
+		payload := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, val, Zero, One))
+		payloadPtr := c.currBlock.NewBitCast(payload, lltypes.NewPointer(c.payloadType(variant)))
+		for i, sub := range p.Patterns {
+			fieldPtr := NewGetElementPtr(c.currBlock, payloadPtr, Zero, constant.NewInt(lltypes.I32, int64(i)))
+			c.matchPattern(sub, NewLoad(c.currBlock, fieldPtr), variant.Types[i], fail)
+		}
+	case *ast.TuplePattern:
+		tupleType := valType.(types.TupleType)
+		for i, sub := range p.Elems {
+			elemPtr := NewGetElementPtr(c.currBlock, val, Zero, constant.NewInt(lltypes.I32, int64(i)))
+			c.matchPattern(sub, NewLoad(c.currBlock, elemPtr), tupleType.Types[i], fail)
+		}
+	case *ast.StructPattern:
+		// The pattern names a generic struct by itself, but the value is one of its instances
+		structDef := c.prog.Struct(valType.(types.StructType).TypeString())
+		for i, field := range p.Fields {
+			offset := constant.NewInt(lltypes.I32, int64(structDef.Offset(field)))
+			memberPtr := NewGetElementPtr(c.currBlock, val, Zero, offset)
+			c.matchPattern(p.Patterns[i], NewLoad(c.currBlock, memberPtr), structDef.MemberType(field), fail)
+		}
+	default:
+		panic("Unknown pattern: " + pattern.String())
+	}
+}
+
+// matchTest continues in a new block if cond is true, and branches to fail otherwise
+func (c *Compiler) matchTest(cond value.Value, fail *ir.Block) {
+	passBlock := c.currFun.NewBlock(c.getLabel("matchpass"))
+	c.currBlock.NewCondBr(cond, passBlock, fail)
+	c.currBlock = passBlock
+}
+
+// valuesEqual compares two values of a type that a literal pattern can have
+func (c *Compiler) valuesEqual(left value.Value, right value.Value, valType types.Type) value.Value {
+	switch valType.(type) {
+	case types.StringType:
+		return c.runtimeBool(c.currBlock.NewCall(StrEq, left, right))
+	case types.FloatType:
+		return c.currBlock.NewFCmp(enum.FPredOEQ, left, right)
+	}
+
+	return c.currBlock.NewICmp(enum.IPredEQ, left, right)
+}
@@ -12,16 +12,14 @@ import (
 )
 
 func (c *Compiler) createString(len value.Value, dataPtr value.Value) value.Value {
-	castLen := c.currBlock.NewSExt(len, lltypes.I64)
-
 	strSize := GetSize(c.currBlock, StrType)
-	totalLen := c.currBlock.NewAdd(castLen, strSize)
+	totalLen := c.currBlock.NewAdd(len, strSize)
 	newStrMem := c.currBlock.NewCall(Malloc, totalLen)
 	newStr := c.currBlock.NewBitCast(newStrMem, lltypes.NewPointer(StrType))
 
 	// Store new length
 	newLenPtr := NewGetElementPtr(c.currBlock, newStr, Zero, Zero)
-	c.currBlock.NewStore(castLen, newLenPtr)
+	c.currBlock.NewStore(len, newLenPtr)
 
 	// Calculate str data pointer
 	newStrDataPtr := NewGetElementPtr(c.currBlock, newStr, One)
@@ -31,7 +29,7 @@ func (c *Compiler) createString(len value.Value, dataPtr value.Value) value.Valu
 	newDataPtr := NewGetElementPtr(c.currBlock, newStr, Zero, One)
 	c.currBlock.NewStore(newStrDataPtr, newDataPtr)
 
-	c.currBlock.NewCall(MemCopy, newStrDataPtr, dataPtr, castLen, constant.False)
+	c.currBlock.NewCall(MemCopy, newStrDataPtr, dataPtr, len, constant.False)
 
 	return newStr
 }
@@ -85,7 +83,7 @@ func (c *Compiler) listPush(list ast.Node, value ast.Node) value.Value {
 	len := c.arrLen(listVal)
 	cap := c.arrCap(listVal)
 
-	newLen := c.currBlock.NewAdd(len, One)
+	newLen := c.currBlock.NewAdd(len, constant.NewInt(IntType, 1))
 	c.setArrLen(listVal, newLen)
 	shouldResize := c.currBlock.NewICmp(enum.IPredUGT, newLen, cap)
 
@@ -105,7 +103,7 @@ func (c *Compiler) listPush(list ast.Node, value ast.Node) value.Value {
 	// Calculate new arr size in bytes
 	listType := c.Type(list).(types.ArrayType)
 	llSubtype := c.llType(listType.Subtype)
-	newByteSize := c.currBlock.NewMul(GetSize(c.currBlock, llSubtype), c.currBlock.NewSExt(newCap, lltypes.I64))
+	newByteSize := c.currBlock.NewMul(GetSize(c.currBlock, llSubtype), newCap)
 
 	dataPtr := c.arrData(listVal)
 	voidDataPtr := c.currBlock.NewBitCast(dataPtr, lltypes.I8Ptr)
@@ -122,9 +120,82 @@ func (c *Compiler) listPush(list ast.Node, value ast.Node) value.Value {
 }
 
 func (c *Compiler) listPop(list ast.Node) value.Value {
+	listVal := c.CompileNode(list)
+
+	// Popping an empty list fails the check with an index of -1
+	lastIndex := c.currBlock.NewSub(c.arrLen(listVal), constant.NewInt(IntType, 1))
+	c.setupBoundsCheck(c.arrLen(listVal), lastIndex)
+
+	popped := NewLoad(c.currBlock, c.getListElemPtr(listVal, lastIndex))
+	c.setArrLen(listVal, lastIndex)
+
+	return popped
+}
+
+// listElemSize is the size in bytes of each element of the list, as the runtime expects it
+func (c *Compiler) listElemSize(list ast.Node) value.Value {
+	listType := c.Type(list).(types.ArrayType)
+	return GetSize(c.currBlock, c.llType(listType.Subtype))
+}
+
+func (c *Compiler) listInsert(list ast.Node, index ast.Node, elem ast.Node) value.Value {
+	listVal := c.CompileNode(list)
+	indexVal := c.CompileNode(index)
+	elemVal := c.CompileNode(elem)
+
+	// Inserting at the end of the list is allowed
+	c.setupBoundsCheck(c.currBlock.NewAdd(c.arrLen(listVal), constant.NewInt(IntType, 1)), indexVal)
+
+	voidList := c.currBlock.NewBitCast(listVal, lltypes.I8Ptr)
+	slot := c.currBlock.NewCall(ListInsert, voidList, indexVal, c.listElemSize(list))
+	c.currBlock.NewStore(elemVal, c.currBlock.NewBitCast(slot, lltypes.NewPointer(elemVal.Type())))
+
+	return nil
+}
+
+func (c *Compiler) listRemove(list ast.Node, index ast.Node) value.Value {
+	listVal := c.CompileNode(list)
+	indexVal := c.CompileNode(index)
+
+	c.setupBoundsCheck(c.arrLen(listVal), indexVal)
+	removed := NewLoad(c.currBlock, c.getListElemPtr(listVal, indexVal))
+
+	voidList := c.currBlock.NewBitCast(listVal, lltypes.I8Ptr)
+	c.currBlock.NewCall(ListRemove, voidList, indexVal, c.listElemSize(list))
+
+	return removed
+}
+
+func (c *Compiler) listExtend(list ast.Node, other ast.Node) value.Value {
+	listVal := c.currBlock.NewBitCast(c.CompileNode(list), lltypes.I8Ptr)
+	otherVal := c.currBlock.NewBitCast(c.CompileNode(other), lltypes.I8Ptr)
+	c.currBlock.NewCall(ListExtend, listVal, otherVal, c.listElemSize(list))
+
 	return nil
 }
 
+func (c *Compiler) listReverse(list ast.Node) value.Value {
+	listVal := c.currBlock.NewBitCast(c.CompileNode(list), lltypes.I8Ptr)
+	c.currBlock.NewCall(ListReverse, listVal, c.listElemSize(list))
+
+	return nil
+}
+
+func (c *Compiler) listClear(list ast.Node) value.Value {
+	listVal := c.CompileNode(list)
+	c.setArrLen(listVal, constant.NewInt(IntType, 0))
+
+	return nil
+}
+
+func (c *Compiler) listIndex(list ast.Node, elem ast.Node) value.Value {
+	listVal := c.currBlock.NewBitCast(c.CompileNode(list), lltypes.I8Ptr)
+	elemVal := c.CompileNode(elem)
+	elemType := c.Type(list).(types.ArrayType).Subtype
+
+	return c.currBlock.NewCall(ListIndex, listVal, c.valueRef(elemVal), c.listElemSize(list), keyKind(elemType))
+}
+
 func (c *Compiler) compileBaseMethod(baseType ast.Node, methodName string, args []ast.Node) value.Value {
 	ty := c.Type(baseType)
 
@@ -135,7 +206,26 @@ func (c *Compiler) compileBaseMethod(baseType ast.Node, methodName string, args
 			return c.listPush(baseType, args[0])
 		case "pop":
 			return c.listPop(baseType)
+		case "insert":
+			return c.listInsert(baseType, args[0], args[1])
+		case "remove":
+			return c.listRemove(baseType, args[0])
+		case "extend":
+			return c.listExtend(baseType, args[0])
+		case "reverse":
+			return c.listReverse(baseType)
+		case "clear":
+			return c.listClear(baseType)
+		case "index":
+			return c.listIndex(baseType, args[0])
+		}
+	case types.MapType:
+		switch methodName {
+		case "delete":
+			return c.mapDelete(baseType, args[0])
 		}
+	case types.StringType:
+		return c.compileStrMethod(baseType, methodName, args)
 	}
 	return nil
 }
@@ -150,12 +240,20 @@ func (c *Compiler) checkBaseMethod(node ast.Node) (ast.Node, string, bool) {
 	fieldName := structAccess.Field.(*ast.Ident).Value
 
 	switch targType.(type) {
-	case types.StructType:
+	case types.StructType, types.InterfaceType:
 		return nil, "", false
 	case types.ArrayType:
 		if types.HasMethod(types.ListMethods, fieldName) {
 			return structAccess.Target, fieldName, true
 		}
+	case types.MapType:
+		if types.HasMethod(types.MapMethods, fieldName) {
+			return structAccess.Target, fieldName, true
+		}
+	case types.StringType:
+		if types.HasMethod(types.StringMethods, fieldName) {
+			return structAccess.Target, fieldName, true
+		}
 	}
 
 	errs.Error(errs.ErrorValue, node, "base type '%s' doesn't have method '%s'", reflect.TypeOf(targType).Name(), fieldName)
@@ -0,0 +1,318 @@
+package compile
+
+import (
+	"dandelion/ast"
+	"dandelion/types"
+	"sort"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	lltypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// compilePrint writes its arguments to stdout separated by spaces and followed by a newline
+func (c *Compiler) compilePrint(node *ast.BuiltinExp) value.Value {
+	for i, arg := range node.Args {
+		if i > 0 {
+			c.printLit(" ")
+		}
+
+		val := c.CompileNode(arg)
+		argType := c.Type(arg)
+		switch argType.(type) {
+		// Strings and bytes are written as they are, they're only quoted inside other values
+		case types.StringType:
+			c.currBlock.NewCall(PrintStr, val)
+		case types.ByteType:
+			c.currBlock.NewCall(PrintByte, val)
+		default:
+			c.currBlock.NewCall(c.printer(argType), val)
+		}
+	}
+	c.printLit("\n")
+
+	return nil
+}
+
+// printLit writes a constant string. Each text is only defined once in the module.
+func (c *Compiler) printLit(text string) {
+	lit, ok := c.printLits[text]
+	if !ok {
+		lit = c.mod.NewGlobalDef(c.getLabel("printlit"), constant.NewCharArrayFromString(text+"\x00"))
+		c.printLits[text] = lit
+	}
+	c.currBlock.NewCall(PrintLit, NewGetElementPtr(c.currBlock, lit, Zero, Zero))
+}
+
+// printer returns the function that writes values of the given type, generating it on first use.
+// It's cached before its body is generated so that recursive types can print themselves.
+func (c *Compiler) printer(ty types.Type) *ir.Func {
+	hash := types.HashType(ty)
+	if fun, ok := c.printers[hash]; ok {
+		return fun
+	}
+
+	val := ir.NewParam("val", c.llType(ty))
+	fun := c.mod.NewFunc(c.getLabel("print"), lltypes.Void, val)
+	c.printers[hash] = fun
+
+	prevFun, prevBlock := c.currFun, c.currBlock
+	c.currFun = fun
+	c.currBlock = fun.NewBlock(c.getLabel("entry"))
+	c.printValue(val, ty)
+	c.currBlock.NewRet(nil)
+	c.currFun, c.currBlock = prevFun, prevBlock
+
+	return fun
+}
+
+func (c *Compiler) printValue(val value.Value, ty types.Type) {
+	switch t := ty.(type) {
+	case types.IntType:
+		c.currBlock.NewCall(PrintInt, val)
+	case types.FloatType:
+		c.currBlock.NewCall(PrintFloat, val)
+	case types.BoolType:
+		c.currBlock.NewCall(PrintBool, val)
+	case types.ByteType:
+		c.printLit("'")
+		c.currBlock.NewCall(PrintByte, val)
+		c.printLit("'")
+	case types.StringType:
+		c.currBlock.NewCall(PrintQuoted, val)
+	case types.ArrayType:
+		c.printNullable(val, func() { c.printList(val, t) })
+	case types.TupleType:
+		c.printNullable(val, func() { c.printTuple(val, t) })
+	case types.MapType:
+		c.printNullable(val, func() { c.printMap(val, t) })
+	case types.StructType:
+		c.printNullable(val, func() { c.printStruct(val, t) })
+	case types.EnumType:
+		c.printEnum(val, t)
+	case types.AnyType:
+		c.printAny(val)
+	case types.InterfaceType:
+		c.printNullable(val, func() { c.printInterface(val, t) })
+	case types.FuncType:
+		c.printLit("<func>")
+	case types.CoroutineType:
+		c.printLit("<coroutine>")
+	default:
+		panic("Can't print value of type " + ty.TypeString())
+	}
+}
+
+// printNullable writes null for null pointers, and otherwise calls printBody to write the value
+func (c *Compiler) printNullable(val value.Value, printBody func()) {
+	nullBlock := c.currFun.NewBlock(c.getLabel("printnull"))
+	valBlock := c.currFun.NewBlock(c.getLabel("printval"))
+	postBlock := c.currFun.NewBlock(c.getLabel("postprint"))
+
+	isNull := c.currBlock.NewICmp(enum.IPredEQ, val, constant.NewNull(val.Type().(*lltypes.PointerType)))
+	c.currBlock.NewCondBr(isNull, nullBlock, valBlock)
+
+	c.currBlock = nullBlock
+	c.printLit("null")
+	c.currBlock.NewBr(postBlock)
+
+	c.currBlock = valBlock
+	printBody()
+	c.currBlock.NewBr(postBlock)
+
+	c.currBlock = postBlock
+}
+
+// printSep writes a comma before every element except the first
+func (c *Compiler) printSep(first value.Value) {
+	sepBlock := c.currFun.NewBlock(c.getLabel("printsep"))
+	elemBlock := c.currFun.NewBlock(c.getLabel("printelem"))
+	c.currBlock.NewCondBr(first, elemBlock, sepBlock)
+
+	c.currBlock = sepBlock
+	c.printLit(", ")
+	c.currBlock.NewBr(elemBlock)
+
+	c.currBlock = elemBlock
+}
+
+func (c *Compiler) printList(list value.Value, listType types.ArrayType) {
+	c.printLit("[")
+
+	indexPtr := c.currBlock.NewAlloca(IntType)
+	c.currBlock.NewStore(constant.NewInt(IntType, 0), indexPtr)
+
+	condBlock := c.currFun.NewBlock(c.getLabel("printlistcond"))
+	bodyBlock := c.currFun.NewBlock(c.getLabel("printlistbody"))
+	postBlock := c.currFun.NewBlock(c.getLabel("postprintlist"))
+	c.currBlock.NewBr(condBlock)
+
+	c.currBlock = condBlock
+	index := NewLoad(c.currBlock, indexPtr)
+	c.currBlock.NewCondBr(c.currBlock.NewICmp(enum.IPredSLT, index, c.arrLen(list)), bodyBlock, postBlock)
+
+	c.currBlock = bodyBlock
+	c.printSep(c.currBlock.NewICmp(enum.IPredEQ, index, constant.NewInt(IntType, 0)))
+	elem := NewLoad(c.currBlock, c.getListElemPtr(list, index))
+	c.currBlock.NewCall(c.printer(listType.Subtype), elem)
+	c.currBlock.NewStore(c.currBlock.NewAdd(index, constant.NewInt(IntType, 1)), indexPtr)
+	c.currBlock.NewBr(condBlock)
+
+	c.currBlock = postBlock
+	c.printLit("]")
+}
+
+func (c *Compiler) printTuple(tuple value.Value, tupleType types.TupleType) {
+	c.printLit("(")
+	for i, elemType := range tupleType.Types {
+		if i > 0 {
+			c.printLit(", ")
+		}
+		elem := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, tuple, Zero, constant.NewInt(lltypes.I32, int64(i))))
+		c.currBlock.NewCall(c.printer(elemType), elem)
+	}
+	c.printLit(")")
+}
+
+func (c *Compiler) printMap(mapVal value.Value, mapType types.MapType) {
+	c.printLit("{")
+
+	firstSlot := c.currBlock.NewCall(MapNext, mapVal, constant.NewInt(lltypes.I32, 0))
+	slotPtr := c.currBlock.NewAlloca(lltypes.I32)
+	c.currBlock.NewStore(firstSlot, slotPtr)
+
+	condBlock := c.currFun.NewBlock(c.getLabel("printmapcond"))
+	bodyBlock := c.currFun.NewBlock(c.getLabel("printmapbody"))
+	postBlock := c.currFun.NewBlock(c.getLabel("postprintmap"))
+	c.currBlock.NewBr(condBlock)
+
+	c.currBlock = condBlock
+	slot := NewLoad(c.currBlock, slotPtr)
+	c.currBlock.NewCondBr(c.currBlock.NewICmp(enum.IPredSGE, slot, constant.NewInt(lltypes.I32, 0)), bodyBlock, postBlock)
+
+	c.currBlock = bodyBlock
+	c.printSep(c.currBlock.NewICmp(enum.IPredEQ, slot, firstSlot))
+	keyPtr := c.currBlock.NewBitCast(c.currBlock.NewCall(MapKey, mapVal, slot), lltypes.NewPointer(c.llType(mapType.Key)))
+	valPtr := c.currBlock.NewBitCast(c.currBlock.NewCall(MapVal, mapVal, slot), lltypes.NewPointer(c.llType(mapType.Value)))
+	c.currBlock.NewCall(c.printer(mapType.Key), NewLoad(c.currBlock, keyPtr))
+	c.printLit(": ")
+	c.currBlock.NewCall(c.printer(mapType.Value), NewLoad(c.currBlock, valPtr))
+	nextSlot := c.currBlock.NewAdd(slot, constant.NewInt(lltypes.I32, 1))
+	c.currBlock.NewStore(c.currBlock.NewCall(MapNext, mapVal, nextSlot), slotPtr)
+	c.currBlock.NewBr(condBlock)
+
+	c.currBlock = postBlock
+	c.printLit("}")
+}
+
+func (c *Compiler) printStruct(structPtr value.Value, structType types.StructType) {
+	structDef := c.prog.Struct(structType.TypeString())
+	c.printLit(structType.TypeString() + "{")
+	for i, member := range structDef.Members {
+		if i > 0 {
+			c.printLit(", ")
+		}
+		c.printLit(member.Name.Value + ": ")
+		memberPtr := NewGetElementPtr(c.currBlock, structPtr, Zero, constant.NewInt(lltypes.I32, int64(i)))
+		c.currBlock.NewCall(c.printer(member.Type), NewLoad(c.currBlock, memberPtr))
+	}
+	c.printLit("}")
+}
+
+// printEnum writes the name of the variant, followed by its values if it has any
+func (c *Compiler) printEnum(enumPtr value.Value, enumType types.EnumType) {
+	enumDef := c.prog.Enum(enumType.Name)
+	postBlock := c.currFun.NewBlock(c.getLabel("postprintenum"))
+	unknownBlock := c.currFun.NewBlock(c.getLabel("printenumunknown"))
+	unknownBlock.NewUnreachable()
+
+	tag := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, enumPtr, Zero, Zero))
+	payload := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, enumPtr, Zero, One))
+	entryBlock := c.currBlock
+
+	cases := make([]*ir.Case, 0)
+	for i, variant := range enumDef.Variants {
+		caseBlock := c.currFun.NewBlock(c.getLabel("printenum"))
+		cases = append(cases, ir.NewCase(constant.NewInt(lltypes.I32, int64(i)), caseBlock))
+		c.currBlock = caseBlock
+
+		c.printLit(variant.Name)
+		if len(variant.Types) > 0 {
+			payloadPtr := c.currBlock.NewBitCast(payload, lltypes.NewPointer(c.payloadType(variant)))
+			c.printLit("(")
+			for k, ty := range variant.Types {
+				if k > 0 {
+					c.printLit(", ")
+				}
+				fieldPtr := NewGetElementPtr(c.currBlock, payloadPtr, Zero, constant.NewInt(lltypes.I32, int64(k)))
+				c.currBlock.NewCall(c.printer(ty), NewLoad(c.currBlock, fieldPtr))
+			}
+			c.printLit(")")
+		}
+		c.currBlock.NewBr(postBlock)
+	}
+	entryBlock.NewSwitch(tag, unknownBlock, cases...)
+
+	c.currBlock = postBlock
+}
+
+// printAny switches on the type tag of the value, and prints it with the printer of the tagged type
+func (c *Compiler) printAny(anyPtr value.Value) {
+	postBlock := c.currFun.NewBlock(c.getLabel("postprintany"))
+	unknownBlock := c.currFun.NewBlock(c.getLabel("printanyunknown"))
+	unknownBlock.NewBr(postBlock)
+
+	tag := NewLoad(c.currBlock, NewGetElementPtr(c.currBlock, anyPtr, Zero, Zero))
+	entryBlock := c.currBlock
+
+	cases := make([]*ir.Case, 0)
+	for _, ty := range c.taggedTypes() {
+		caseBlock := c.currFun.NewBlock(c.getLabel("printany"))
+		cases = append(cases, ir.NewCase(constant.NewInt(lltypes.I32, int64(c.typeTable.GetNo(ty))), caseBlock))
+		c.currBlock = caseBlock
+
+		llType := c.llType(ty)
+		var valPtr value.Value
+		if _, isPtr := llType.(*lltypes.PointerType); isPtr {
+			valPtr = NewGetElementPtr(c.currBlock, anyPtr, Zero, One)
+		} else {
+			valPtr = NewGetElementPtr(c.currBlock, anyPtr, Zero, constant.NewInt(lltypes.I32, 2))
+		}
+		val := c.currBlock.NewLoad(llType, c.currBlock.NewBitCast(valPtr, lltypes.NewPointer(llType)))
+		c.currBlock.NewCall(c.printer(ty), val)
+		c.currBlock.NewBr(postBlock)
+	}
+	entryBlock.NewSwitch(tag, unknownBlock, cases...)
+
+	c.currBlock = postBlock
+}
+
+// taggedTypes returns every type that can be stored in an any value, ordered by type number
+func (c *Compiler) taggedTypes() []types.Type {
+	tagged := make(map[types.TypeHash]types.Type)
+	addType := func(ty types.Type) {
+		switch ty.(type) {
+		case types.AnyType, types.VoidType:
+			return
+		}
+		tagged[types.HashType(ty)] = ty
+	}
+	for _, ty := range c.Types {
+		addType(ty)
+	}
+	for _, ty := range c.prog.RefTypes {
+		addType(ty)
+	}
+
+	tys := make([]types.Type, 0)
+	for _, ty := range tagged {
+		tys = append(tys, ty)
+	}
+	sort.Slice(tys, func(i, j int) bool {
+		return c.typeTable.GetNo(tys[i]) < c.typeTable.GetNo(tys[j])
+	})
+
+	return tys
+}
@@ -20,10 +20,11 @@ import (
 )
 
 func RunProg(progText string) (string, int) {
-	prog := parser.ParseProgram(progText)
+	prog := parser.ParseSource(progText, "")
 	errs.SetProg(prog)
 	fmt.Println(prog)
 	transform.TransformAst(prog)
+	infer.SpecializeGenerics(prog)
 
 	progTypes := infer.InferTypes(prog)
 	typecheck.ValidateProg(prog, progTypes)
@@ -79,8 +80,10 @@ func CompileCheckOutput(progText string, output string) bool {
 	return true
 }
 
-func ExecIR(llvmIr string) error {
-	cmd := exec.Command("lli", "-load", "lib/lib.so", "-load", "lib/libgc.so")
+// ExecIR runs a program's IR with the JIT, passing it args
+func ExecIR(llvmIr string, args []string) error {
+	lliArgs := append([]string{"-load", "lib/lib.so", "-load", "lib/libgc.so", "-"}, args...)
+	cmd := exec.Command("lli", lliArgs...)
 	buffer := bytes.NewBufferString(llvmIr)
 
 	cmd.Stdin = buffer
@@ -108,10 +111,12 @@ func ExecIR(llvmIr string) error {
 	return nil
 }
 
-func CompileSource(progText string, optLevel int) string {
-	prog := parser.ParseProgram(progText)
+// CompileSource compiles the program in the file at path. Path is empty for programs that aren't in a file.
+func CompileSource(progText string, path string, optLevel int) string {
+	prog := parser.ParseSource(progText, path)
 	errs.SetProg(prog)
 	transform.TransformAst(prog)
+	infer.SpecializeGenerics(prog)
 
 	progTypes := infer.InferTypes(prog)
 	typecheck.ValidateProg(prog, progTypes)
@@ -167,6 +172,16 @@ func MakeBinary(ir string, optLevel int, outFile string) string {
 		filepath.Join(objDir, "gc.a"),
 		filepath.Join(objDir, "alloc.o"),
 		filepath.Join(objDir, "exception.o"),
+		filepath.Join(objDir, "map.o"),
+		filepath.Join(objDir, "command.o"),
+		filepath.Join(objDir, "format.o"),
+		filepath.Join(objDir, "list.o"),
+		filepath.Join(objDir, "slice.o"),
+		filepath.Join(objDir, "strings.o"),
+		filepath.Join(objDir, "convert.o"),
+		filepath.Join(objDir, "print.o"),
+		filepath.Join(objDir, "process.o"),
+		filepath.Join(objDir, "file.o"),
 		filepath.Join(objName),
 	}
 
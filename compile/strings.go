@@ -0,0 +1,50 @@
+package compile
+
+import (
+	"dandelion/ast"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	lltypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// compileStrMethod calls the runtime routine backing a string method, with the string as the first argument
+func (c *Compiler) compileStrMethod(target ast.Node, methodName string, args []ast.Node) value.Value {
+	argVals := []value.Value{c.CompileNode(target)}
+	for _, arg := range args {
+		argVals = append(argVals, c.CompileNode(arg))
+	}
+
+	switch methodName {
+	case "split":
+		return c.currBlock.NewCall(StrSplit, argVals...)
+	case "join":
+		return c.currBlock.NewCall(StrJoin, argVals...)
+	case "trim":
+		return c.currBlock.NewCall(StrTrim, argVals...)
+	case "find":
+		return c.currBlock.NewCall(StrFind, argVals...)
+	case "contains":
+		found := c.currBlock.NewCall(StrFind, argVals...)
+		return c.currBlock.NewICmp(enum.IPredSGE, found, constant.NewInt(IntType, 0))
+	case "replace":
+		return c.currBlock.NewCall(StrReplace, argVals...)
+	case "startswith":
+		return c.runtimeBool(c.currBlock.NewCall(StrStartsWith, argVals...))
+	case "endswith":
+		return c.runtimeBool(c.currBlock.NewCall(StrEndsWith, argVals...))
+	case "upper":
+		return c.currBlock.NewCall(StrUpper, argVals...)
+	case "lower":
+		return c.currBlock.NewCall(StrLower, argVals...)
+	case "repeat":
+		return c.currBlock.NewCall(StrRepeat, argVals...)
+	}
+
+	panic("Unknown string method: " + methodName)
+}
+
+// runtimeBool converts the int the runtime uses for truth values into a bool
+func (c *Compiler) runtimeBool(val value.Value) value.Value {
+	return c.currBlock.NewICmp(enum.IPredNE, val, constant.NewInt(lltypes.I32, 0))
+}
@@ -0,0 +1,813 @@
+package infer
+
+import (
+	"dandelion/ast"
+	"dandelion/errs"
+	"dandelion/transform"
+	"dandelion/types"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Generic functions can be used at more than one type, like 'id = f(x) { x }'. Type refs are shared by
+// name, so every use of a function would normally get the same type. Instead each generic function is
+// inferred on its own first, and its type is generalized: every use gets a fresh instance of it. Uses
+// that end up at the same type share a specialized copy of the function, so the rest of the compiler
+// only ever sees monomorphic functions.
+//
+// Only functions that don't capture anything are generalized, so a function that refers to another generic
+// function isn't generic itself. Closures keep a single type, and so does a generic function inside a
+// closure that captures it. Using either at more than one type is reported as an error.
+//
+// Specialization is its own stage between the transforms and inference, since it changes the program.
+//
+// Generic structs work the same way through their constructors. Each type a constructor is used at
+// gets a concrete struct, like Stack[int], with its own copies of the generic struct's methods.
+
+// generic is a function that might be used at more than one type
+type generic struct {
+	fName   string      // Name of the lifted function
+	varName string      // The name the function is bound to
+	bind    *ast.Assign // The assignment that binds the function to its name
+	block   *ast.Block  // The block the binding is in
+	// Every use of the bound name. A use is either a single identifier, or a capture of the name by
+	// a closure, along with all of the identifiers that refer to it inside the closure.
+	uses       [][]*ast.Ident
+	capturedBy []string     // The closure that captures each use, or an empty string for direct uses
+	closure    *ast.Closure // Set for functions that capture names, which can't be generalized
+}
+
+// captureError is a function with captures that's used at more than one type
+type captureError struct {
+	node ast.Node
+	msg  string
+}
+
+func (e *captureError) Error() string {
+	return e.msg
+}
+
+// SpecializeGenerics gives each type that a generic function is used at its own copy of the function. It
+// runs before inference, which only ever sees monomorphic functions.
+func SpecializeGenerics(prog *ast.Program) {
+	err := specializeGenerics(prog)
+	if err != nil {
+		errs.Error(errs.ErrorType, err.node, err.Error())
+		errs.CheckExit()
+	}
+}
+
+func specializeGenerics(prog *ast.Program) *captureError {
+	generics := findGenerics(prog)
+	closures := findClosures(prog)
+	if len(generics) == 0 && len(closures) == 0 {
+		return nil
+	}
+
+	useTypes := instantiateGenerics(prog, generics)
+	if useTypes == nil {
+		err := checkClosures(prog, generics, closures)
+		if err == nil {
+			err = checkCaptures(prog, generics)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(generics) == 0 {
+		return nil
+	}
+
+	if useTypes != nil {
+		instantiateStructs(prog, generics, useTypes)
+	}
+
+	for _, gen := range generics {
+		if useTypes != nil && gen.specialize(prog, useTypes[gen.fName]) {
+			continue
+		}
+		gen.renameUses(func(int) string { return gen.varName })
+
+		template := templateDef(prog.Funcs[gen.fName])
+		if useTypes != nil && template != nil {
+			errs.Error(errs.ErrorType, gen.bind, "can't infer the type arguments of struct '%s'", template.Type.Name)
+			errs.CheckExit()
+		}
+	}
+
+	// Only the instances of generic structs are compiled
+	for k := 0; k < prog.StructCount(); k++ {
+		template := prog.StructNo(k)
+		if template.Generic() && useTypes != nil {
+			for _, method := range template.Methods {
+				delete(prog.Funcs, method.TargetName)
+			}
+			template.Methods = nil
+		}
+	}
+
+	return nil
+}
+
+// checkClosures finds the closure that keeps the program from type checking by being used at more than one
+// type, if there is one. Each closure is tried on its own as if it were generic.
+func checkClosures(prog *ast.Program, generics []*generic, closures []*generic) *captureError {
+	for _, clo := range closures {
+		useTypes := instantiateGenerics(prog, append(generics[:len(generics):len(generics)], clo))
+		clo.renameUses(func(int) string { return clo.varName })
+		if useTypes == nil {
+			continue
+		}
+
+		captured := make([]string, len(clo.closure.Unbound))
+		for k, name := range clo.closure.Unbound {
+			captured[k] = "'" + transform.BaseName(name) + "'"
+		}
+		sort.Strings(captured)
+
+		// The closure's binding is made by a transform, so the error is reported where it's used
+		return &captureError{clo.uses[1][0], fmt.Sprintf("can't use '%s' at more than one type, since it captures %s",
+			transform.BaseName(clo.varName), strings.Join(captured, ", "))}
+	}
+
+	return nil
+}
+
+// checkCaptures finds the generic function that keeps the program from type checking by being used at more
+// than one type inside a closure that captures it, if there is one. Each capture is tried on its own as if
+// every identifier in it were a separate use.
+func checkCaptures(prog *ast.Program, generics []*generic) *captureError {
+	for k, gen := range generics {
+		for n, use := range gen.uses {
+			if gen.capturedBy[n] == "" {
+				continue
+			}
+
+			split := *gen
+			split.uses = append(append([][]*ast.Ident{}, gen.uses[:n]...), gen.uses[n+1:]...)
+			for _, ident := range use {
+				split.uses = append(split.uses, []*ast.Ident{ident})
+			}
+			trial := append([]*generic{}, generics...)
+			trial[k] = &split
+			useTypes := instantiateGenerics(prog, trial)
+			for _, other := range generics {
+				other.renameUses(func(int) string { return other.varName })
+			}
+			if useTypes == nil {
+				continue
+			}
+
+			captor := transform.BaseName(gen.capturedBy[n])
+			return &captureError{use[len(use)-1], fmt.Sprintf("can't use '%s' at more than one type in '%s', since '%s' captures it",
+				transform.BaseName(gen.varName), captor, captor)}
+		}
+	}
+
+	return nil
+}
+
+// instantiateStructs creates a concrete struct for each instance of a generic struct that the program uses
+func instantiateStructs(prog *ast.Program, generics []*generic, useTypes map[string][]types.Type) {
+	for _, gen := range generics {
+		if templateDef(prog.Funcs[gen.fName]) == nil {
+			continue
+		}
+		for _, useType := range useTypes[gen.fName] {
+			if useType != nil {
+				instantiateTypeStructs(prog, useType, gen.bind)
+			}
+		}
+	}
+
+	// Instances can also be named by type hints without being constructed directly
+	for _, refType := range prog.RefTypes {
+		instantiateTypeStructs(prog, refType, nil)
+	}
+	for _, fun := range prog.Funcs {
+		if fun.TypeHint != nil {
+			instantiateTypeStructs(prog, *fun.TypeHint, fun)
+		}
+		ast.WalkAst(fun, &ast.BaseWalker{
+			WalkN: func(node ast.Node) ast.Node {
+				meta := prog.Meta(node)
+				if meta != nil && meta.Hint != nil {
+					instantiateTypeStructs(prog, meta.Hint, node)
+				}
+				extern, isExtern := node.(*ast.Extern)
+				if isExtern {
+					instantiateTypeStructs(prog, extern.Type, node)
+				}
+				return nil
+			},
+			WalkB: func(block *ast.Block) *ast.Block {
+				return nil
+			},
+		})
+	}
+}
+
+func instantiateTypeStructs(prog *ast.Program, t types.Type, source ast.Node) {
+	for _, structType := range types.GenericStructs(t) {
+		instantiateStruct(prog, structType, source)
+	}
+}
+
+// instantiateStruct creates the concrete struct for an instance of a generic struct, if it doesn't exist yet
+func instantiateStruct(prog *ast.Program, structType types.StructType, source ast.Node) *ast.StructDef {
+	structDef := prog.Struct(structType.TypeString())
+	if structDef != nil {
+		return structDef
+	}
+
+	template := prog.Struct(structType.Name)
+	if template == nil || !template.Generic() {
+		errs.Error(errs.ErrorType, source, "'%s' isn't a generic struct", structType.Name)
+		errs.CheckExit()
+	}
+	if len(template.Params) != len(structType.Params) {
+		errs.Error(errs.ErrorType, source, "struct '%s' takes %d type arguments, not %d", structType.Name,
+			len(template.Params), len(structType.Params))
+		errs.CheckExit()
+	}
+
+	args := make(map[string]types.Type)
+	for k, param := range template.Params {
+		args[param] = structType.Params[k]
+	}
+	members := make([]*ast.StructMember, len(template.Members))
+	for k, member := range template.Members {
+		members[k] = &ast.StructMember{member.Name, types.Substitute(member.Type, args), member.NodeID}
+	}
+	structDef = &ast.StructDef{members, nil, structType, nil, template.NodeID}
+	prog.AddStruct(fmt.Sprintf("s_%d", prog.StructCount()+1), structDef)
+
+	// Members can be instances of other generic structs
+	for _, member := range members {
+		instantiateTypeStructs(prog, member.Type, source)
+	}
+
+	suffix := fmt.Sprintf(".%d", prog.StructCount())
+	for _, method := range template.Methods {
+		targetName := structType.TypeString() + ".method." + method.Name
+		methodFunc := copyFunc(prog, prog.Funcs[method.TargetName], suffix)
+
+		// The 'this' argument is the concrete struct
+		hint := *methodFunc.TypeHint
+		hint.ArgTypes = append([]types.Type{structType}, hint.ArgTypes[1:]...)
+		methodFunc.TypeHint = &hint
+
+		prog.Funcs[targetName] = methodFunc
+		structDef.Methods = append(structDef.Methods, &ast.StructMethod{method.Name, targetName})
+	}
+
+	return structDef
+}
+
+// templateDef returns the generic struct that a function constructs, or nil if it isn't a generic struct's constructor
+func templateDef(fun *ast.FunDef) *ast.StructDef {
+	if fun == nil || len(fun.Body.Lines) != 1 {
+		return nil
+	}
+	instance, isInstance := fun.Body.Lines[0].(*ast.StructInstance)
+	if !isInstance || !instance.DefRef.Generic() {
+		return nil
+	}
+
+	return instance.DefRef
+}
+
+// instantiateGenerics infers the types that each generic function is used at. It returns nil if the
+// program doesn't type check, so that the error is reported by regular inference.
+func instantiateGenerics(prog *ast.Program, generics []*generic) map[string][]types.Type {
+	i := NewInferer()
+	i.prog = prog
+
+	// Infer generic functions first, so their types only contain what their own bodies require
+	genericFuncs := make(map[string]*ast.FunDef)
+	otherFuncs := make(map[string]*ast.FunDef)
+	for name, fun := range prog.Funcs {
+		otherFuncs[name] = fun
+	}
+	for _, gen := range generics {
+		genericFuncs[gen.fName] = prog.Funcs[gen.fName]
+		delete(otherFuncs, gen.fName)
+
+		// A generic struct's methods are part of the type of its constructor
+		template := templateDef(prog.Funcs[gen.fName])
+		if template != nil {
+			for _, method := range template.Methods {
+				genericFuncs[method.TargetName] = prog.Funcs[method.TargetName]
+				delete(otherFuncs, method.TargetName)
+			}
+		}
+	}
+	i.inferFuncs(genericFuncs)
+	if unifyFrom(i, 0) != nil {
+		return nil
+	}
+
+	// Every use gets its own name, and a fresh instance of the generic function's type
+	start := len(i.cons)
+	for _, gen := range generics {
+		gen.renameUses(func(k int) string { return fmt.Sprintf("%s.use%d", gen.varName, k) })
+		scheme := i.TypeRef(prog.Funcs[gen.fName])
+		for _, use := range gen.uses {
+			instance := newInstantiator(i).instantiate(scheme)
+			if gen.closure != nil {
+				// A closure is called without the argument that holds its captures
+				instFunc := i.Resolve(instance).(TypeFunc)
+				instance = i.FuncRef(KindFunc, instFunc.Ret, instFunc.Args[1:]...)
+			}
+			i.AddCons(i.TypeRef(use[0]), instance)
+		}
+	}
+	i.inferFuncs(otherFuncs)
+	if unifyFrom(i, start) != nil {
+		return nil
+	}
+
+	useTypes := make(map[string][]types.Type)
+	for _, gen := range generics {
+		for _, use := range gen.uses {
+			useTypes[gen.fName] = append(useTypes[gen.fName], resolveUse(i, use[0]))
+		}
+	}
+
+	return useTypes
+}
+
+// resolveUse returns the type a generic function is used at, or nil if not enough is known about it
+func resolveUse(i *Inferer, use *ast.Ident) types.Type {
+	useType, err := NewResolver(i).resolve(i.TypeRef(use))
+	if err != nil {
+		return nil
+	}
+
+	return useType
+}
+
+// specialize replaces the generic function with a copy for each type it's used at. It returns false
+// if the function doesn't need to be specialized. A generic struct's constructor is always specialized,
+// since only its instances are real types.
+func (g *generic) specialize(prog *ast.Program, useTypes []types.Type) bool {
+	template := templateDef(prog.Funcs[g.fName])
+	instTypes := make([]types.Type, 0)
+	useInsts := make([]int, len(useTypes))
+	for k, useType := range useTypes {
+		if useType == nil {
+			return false
+		}
+
+		useInsts[k] = -1
+		for n, instType := range instTypes {
+			if types.Equals(useType, instType) {
+				useInsts[k] = n
+			}
+		}
+		if useInsts[k] == -1 {
+			useInsts[k] = len(instTypes)
+			instTypes = append(instTypes, useType)
+		}
+	}
+	if len(instTypes) < 2 && template == nil {
+		return false
+	}
+
+	instNames := make([]string, len(instTypes))
+	binds := make([]ast.Node, len(instTypes))
+	for n := range instTypes {
+		suffix := fmt.Sprintf(".%d", n+1)
+		instNames[n] = g.varName + suffix
+		instFunc := transform.TrimFunSuffix(g.fName) + suffix + transform.FunSuffix
+
+		prog.Funcs[instFunc] = copyFunc(prog, prog.Funcs[g.fName], suffix)
+		if template != nil {
+			// Each copy of the constructor builds one instance of the struct
+			structDef := prog.Struct(instTypes[n].(types.FuncType).RetType.TypeString())
+			prog.Funcs[instFunc].Body.Lines[0].(*ast.StructInstance).DefRef = structDef
+			memberTypes := make([]types.Type, len(structDef.Members))
+			for k, member := range structDef.Members {
+				memberTypes[k] = member.Type
+			}
+			prog.Funcs[instFunc].TypeHint = &types.FuncType{memberTypes, structDef.Type}
+		}
+		binds[n] = &ast.Assign{&ast.Ident{instNames[n], prog.NewNodeID()}, &ast.Ident{instFunc, prog.NewNodeID()}, prog.NewNodeID()}
+		if prog.Meta(g.bind) != nil {
+			*prog.Meta(binds[n]) = *prog.Meta(g.bind)
+		}
+	}
+	delete(prog.Funcs, g.fName)
+
+	// Bind each specialized copy where the generic function was bound
+	lines := make([]ast.Node, 0)
+	for _, line := range g.block.Lines {
+		if line == g.bind {
+			lines = append(lines, binds...)
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	g.block.Lines = lines
+
+	g.renameUses(func(k int) string { return instNames[useInsts[k]] })
+	return true
+}
+
+func (g *generic) renameUses(name func(int) string) {
+	for k, use := range g.uses {
+		for _, ident := range use {
+			ident.Value = name(k)
+		}
+	}
+}
+
+// findGenerics finds the functions that are bound to a name once, and used through that name more than once
+func findGenerics(prog *ast.Program) []*generic {
+	generics := make([]*generic, 0)
+
+	for _, owner := range prog.Funcs {
+		binds := make([]*generic, 0)
+		ast.WalkAst(owner, &ast.BaseWalker{
+			WalkN: func(node ast.Node) ast.Node {
+				return nil
+			},
+			WalkB: func(block *ast.Block) *ast.Block {
+				for _, line := range block.Lines {
+					assign, isAssign := line.(*ast.Assign)
+					if !isAssign {
+						continue
+					}
+					target, isTargetIdent := assign.Target.(*ast.Ident)
+					fun, isFunIdent := assign.Expr.(*ast.Ident)
+					if isTargetIdent && isFunIdent && isGeneric(prog, fun.Value) {
+						binds = append(binds, &generic{fName: fun.Value, varName: target.Value, bind: assign, block: block})
+					}
+				}
+				return nil
+			},
+		})
+
+		for _, gen := range binds {
+			if !gen.findUses(prog, owner) {
+				continue
+			}
+			isTemplate := templateDef(prog.Funcs[gen.fName]) != nil
+			// A capture is checked even if it's the only use, since the closure can use the function more than once
+			isCaptured := len(gen.uses) == 1 && gen.capturedBy[0] != ""
+			if len(gen.uses) > 1 || isTemplate || isCaptured {
+				generics = append(generics, gen)
+			}
+		}
+	}
+
+	return generics
+}
+
+// findClosures finds the closures that are bound to a name and called through it more than once. Only
+// direct calls are uses, which is enough to tell if a closure is called at more than one type.
+func findClosures(prog *ast.Program) []*generic {
+	closures := make([]*generic, 0)
+
+	for _, owner := range prog.Funcs {
+		binds := make([]*generic, 0)
+		ast.WalkAst(owner, &ast.BaseWalker{
+			WalkN: func(node ast.Node) ast.Node {
+				assign, isAssign := node.(*ast.Assign)
+				if !isAssign {
+					return nil
+				}
+				target, isTargetIdent := assign.Target.(*ast.Ident)
+				closure, isClosure := assign.Expr.(*ast.Closure)
+				if isTargetIdent && isClosure {
+					fName := closure.Target.(*ast.Ident).Value
+					binds = append(binds, &generic{fName: fName, varName: target.Value, bind: assign, closure: closure})
+				}
+				return nil
+			},
+			WalkB: func(block *ast.Block) *ast.Block {
+				return nil
+			},
+		})
+
+		for _, clo := range binds {
+			ast.WalkAst(owner, &ast.BaseWalker{
+				WalkN: func(node ast.Node) ast.Node {
+					app, isApp := node.(*ast.FunApp)
+					if !isApp {
+						return nil
+					}
+					target, isIdent := app.Fun.(*ast.Ident)
+					if isIdent && target.Value == clo.varName {
+						clo.uses = append(clo.uses, []*ast.Ident{target})
+					}
+					return nil
+				},
+				WalkB: func(block *ast.Block) *ast.Block {
+					return nil
+				},
+			})
+			if len(clo.uses) > 1 {
+				closures = append(closures, clo)
+			}
+		}
+	}
+
+	return closures
+}
+
+// findUses collects the uses of a generic function's name. It returns false if the name is reassigned.
+func (g *generic) findUses(prog *ast.Program, owner *ast.FunDef) bool {
+	assigns := 0
+	captured := make(map[*ast.Ident]bool)
+	bindTarget := g.bind.Target.(*ast.Ident)
+
+	ast.WalkAst(owner, &ast.BaseWalker{
+		WalkN: func(node ast.Node) ast.Node {
+			switch node := node.(type) {
+			case *ast.Assign:
+				target, isIdent := node.Target.(*ast.Ident)
+				if !isIdent {
+					break
+				}
+				if target.Value == g.varName {
+					assigns++
+				}
+
+				// Names captured by a closure are used inside the closure at a single type
+				tuple, isTuple := node.Expr.(*ast.TupleLiteral)
+				if !isTuple || !strings.HasSuffix(target.Value, transform.CloTupSuffix) {
+					break
+				}
+				for _, elem := range tuple.Exprs {
+					ident := elem.(*ast.Ident)
+					if ident.Value == g.varName {
+						captured[ident] = true
+						use := append([]*ast.Ident{ident}, closureIdents(prog, transform.CloTupToFunc(target.Value), g.varName)...)
+						g.uses = append(g.uses, use)
+						g.capturedBy = append(g.capturedBy, transform.CloTupToFunc(target.Value))
+					}
+				}
+			case *ast.Ident:
+				if node.Value == g.varName && node != bindTarget && !captured[node] {
+					g.uses = append(g.uses, []*ast.Ident{node})
+					g.capturedBy = append(g.capturedBy, "")
+				}
+			}
+			return nil
+		},
+		WalkB: func(block *ast.Block) *ast.Block {
+			return nil
+		},
+	})
+
+	return assigns == 1
+}
+
+// closureIdents returns the identifiers with a name inside a closure, and the closures that it creates
+func closureIdents(prog *ast.Program, fName string, name string) []*ast.Ident {
+	idents := make([]*ast.Ident, 0)
+	ast.WalkAst(prog.Funcs[fName], &ast.BaseWalker{
+		WalkN: func(node ast.Node) ast.Node {
+			switch node := node.(type) {
+			case *ast.Assign:
+				target, isIdent := node.Target.(*ast.Ident)
+				tuple, isTuple := node.Expr.(*ast.TupleLiteral)
+				if !isIdent || !isTuple || !strings.HasSuffix(target.Value, transform.CloTupSuffix) {
+					break
+				}
+				for _, elem := range tuple.Exprs {
+					if elem.(*ast.Ident).Value == name {
+						idents = append(idents, closureIdents(prog, transform.CloTupToFunc(target.Value), name)...)
+					}
+				}
+			case *ast.Ident:
+				if node.Value == name {
+					idents = append(idents, node)
+				}
+			}
+			return nil
+		},
+		WalkB: func(block *ast.Block) *ast.Block {
+			return nil
+		},
+	})
+
+	return idents
+}
+
+// isGeneric returns true if a function can be generalized. Its body can only refer to names it defines.
+func isGeneric(prog *ast.Program, fName string) bool {
+	fun, ok := prog.Funcs[fName]
+	if !ok || fName == "main" || (fun.IsCoro != nil && *fun.IsCoro) {
+		return false
+	}
+
+	defined := make(map[string]bool)
+	for _, arg := range fun.Args {
+		if transform.IsCloArg(arg) {
+			return false
+		}
+		defined[arg.(*ast.Ident).Value] = true
+	}
+
+	finder := &transform.UnboundFinder{defined, make(transform.UnboundVars)}
+	ast.WalkAst(fun, finder)
+
+	return len(finder.Unbound) == 0
+}
+
+// copyFunc copies a function, giving every node a new ID and adding a suffix to every name it defines
+func copyFunc(prog *ast.Program, fun *ast.FunDef, suffix string) *ast.FunDef {
+	defined := make(map[string]bool)
+	for _, arg := range fun.Args {
+		defined[arg.(*ast.Ident).Value] = true
+	}
+	finder := &transform.UnboundFinder{defined, make(transform.UnboundVars)}
+	ast.WalkAst(fun, finder)
+
+	// Walking rebuilds every node that has children, but leaves have to be copied by hand
+	newFun := ast.WalkAst(fun, &ast.BaseWalker{
+		WalkN: func(node ast.Node) ast.Node {
+			switch node := node.(type) {
+			case *ast.Ident:
+				newNode := *node
+				return &newNode
+			case *ast.Num:
+				newNode := *node
+				return &newNode
+			case *ast.StrExp:
+				newNode := *node
+				return &newNode
+			case *ast.BoolExp:
+				newNode := *node
+				return &newNode
+			case *ast.NullExp:
+				newNode := *node
+				return &newNode
+			case *ast.ByteExp:
+				newNode := *node
+				return &newNode
+			case *ast.FloatExp:
+				newNode := *node
+				return &newNode
+			case *ast.FlowControl:
+				newNode := *node
+				return &newNode
+			}
+			return nil
+		},
+		WalkB: func(block *ast.Block) *ast.Block {
+			return nil
+		},
+	}).(*ast.FunDef)
+
+	// The copy is safe to change in place. Types are keyed by the contents of nodes, so anything that
+	// tells apart otherwise equal nodes has to be unique to the copy.
+	ast.WalkAst(newFun, &ast.BaseWalker{
+		WalkN: func(node ast.Node) ast.Node {
+			newID := prog.NewNodeID()
+			oldMeta := prog.Meta(node)
+			if oldMeta != nil {
+				*prog.Meta(&ast.Ident{"", newID}) = *oldMeta
+			}
+			ast.SetID(node, newID)
+
+			switch node := node.(type) {
+			case *ast.Ident:
+				if defined[node.Value] {
+					node.Value += suffix
+				}
+			case *ast.ArrayLiteral:
+				if node.EmptyNo > 0 {
+					node.EmptyNo = int(newID)
+				}
+			case *ast.MapLiteral:
+				if node.EmptyNo > 0 {
+					node.EmptyNo = int(newID)
+				}
+			case *ast.NullExp:
+				node.NullID = int(newID)
+			}
+			return nil
+		},
+		WalkB: func(block *ast.Block) *ast.Block {
+			return nil
+		},
+	})
+
+	return newFun
+}
+
+// instantiator copies a type, replacing every type variable in it with a fresh one
+type instantiator struct {
+	i     *Inferer
+	vars  map[TypeVar]TypeRef
+	funcs map[int]TypeRef
+	metas map[int]TypeRef
+}
+
+func newInstantiator(i *Inferer) *instantiator {
+	return &instantiator{i, make(map[TypeVar]TypeRef), make(map[int]TypeRef), make(map[int]TypeRef)}
+}
+
+func (n *instantiator) instantiate(ref TypeRef) TypeRef {
+	if !n.i.hasVars(ref, make(map[StoreKey]bool)) {
+		return ref
+	}
+
+	switch ty := n.i.Resolve(ref).(type) {
+	case TypeVar:
+		newRef, ok := n.vars[ty]
+		if !ok {
+			newRef = n.i.NewVar()
+			n.vars[ty] = newRef
+		}
+		return newRef
+	case TypeFunc:
+		newRef, ok := n.funcs[ty.ID]
+		if ok {
+			return newRef
+		}
+
+		// Types can refer to themselves, so the new ref has to exist before its parts are copied
+		newRef = n.i.FuncRef(ty.Kind, ty.Ret, ty.Args...)
+		n.funcs[ty.ID] = newRef
+		newFunc := n.i.Resolve(newRef).(TypeFunc)
+		newFunc.Args = make([]TypeRef, len(ty.Args))
+		for k, arg := range ty.Args {
+			newFunc.Args[k] = n.instantiate(arg)
+		}
+		newFunc.Ret = n.instantiate(ty.Ret)
+		n.i.varList[newRef] = newFunc
+
+		return newRef
+	case FuncMeta:
+		newRef, ok := n.metas[ty.ID]
+		if ok {
+			return newRef
+		}
+
+		switch data := ty.data.(type) {
+		case map[int]TypeRef:
+			newData := make(map[int]TypeRef)
+			newRef = n.i.FuncMeta(newData)
+			n.metas[ty.ID] = newRef
+			for k, elem := range data {
+				newData[k] = n.instantiate(elem)
+			}
+		case map[string]TypeRef:
+			newData := make(map[string]TypeRef)
+			newRef = n.i.FuncMeta(newData)
+			n.metas[ty.ID] = newRef
+			for k, elem := range data {
+				newData[k] = n.instantiate(elem)
+			}
+		default:
+			return ref
+		}
+
+		return newRef
+	}
+
+	return ref
+}
+
+// hasVars returns true if a type contains any unresolved type variables
+func (i *Inferer) hasVars(ref TypeRef, seen map[StoreKey]bool) bool {
+	res := i.Resolve(ref)
+	if seen[res.Key()] {
+		return false
+	}
+	seen[res.Key()] = true
+
+	switch ty := res.(type) {
+	case TypeVar:
+		return true
+	case TypeFunc:
+		for _, arg := range ty.Args {
+			if i.hasVars(arg, seen) {
+				return true
+			}
+		}
+		return i.hasVars(ty.Ret, seen)
+	case FuncMeta:
+		switch data := ty.data.(type) {
+		case map[int]TypeRef:
+			for _, elem := range data {
+				if i.hasVars(elem, seen) {
+					return true
+				}
+			}
+		case map[string]TypeRef:
+			for _, elem := range data {
+				if i.hasVars(elem, seen) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
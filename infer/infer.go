@@ -2,6 +2,7 @@ package infer
 
 import (
 	"dandelion/ast"
+	"dandelion/errs"
 	"dandelion/transform"
 	"dandelion/types"
 	"fmt"
@@ -19,7 +20,9 @@ type Inferer struct {
 	cons []*TCons
 	currMeta int
 	funLookup map[string]TypeRef
+	typeParams map[string]TypeRef // Refs for the type parameters of the generic struct being set up
 	structRefs map[*ast.StructDef]TypeRef // Cache these defs for recursive structs
+	ifaceRefs map[*ast.InterfaceDef]TypeRef
 }
 
 func NewInferer() *Inferer {
@@ -28,6 +31,7 @@ func NewInferer() *Inferer {
 	i.refs = make(map[ast.NodeHash]TypeRef)
 	i.funLookup = make(map[string]TypeRef)
 	i.structRefs = make(map[*ast.StructDef]TypeRef)
+	i.ifaceRefs = make(map[*ast.InterfaceDef]TypeRef)
 	return i
 }
 
@@ -58,8 +62,14 @@ func InferTypes(prog *ast.Program) map[ast.NodeHash]types.Type {
 }
 
 func (i *Inferer) inferProg(prog *ast.Program) {
+	i.inferFuncs(prog.Funcs)
+}
+
+func (i *Inferer) inferFuncs(funcs map[string]*ast.FunDef) {
+	prog := i.prog
+
 	// Give all functions a basic type ref that they can reference
-	for name, fun := range prog.Funcs {
+	for name, fun := range funcs {
 		i.funLookup[name] = i.funDefCons(name, fun, false)
 
 		// Add the function name to the global scope
@@ -70,7 +80,7 @@ func (i *Inferer) inferProg(prog *ast.Program) {
 		}
 	}
 
-	for name, fun := range prog.Funcs {
+	for name, fun := range funcs {
 		i.currFunc = name
 		ast.WalkAst(fun, i)
 	}
@@ -103,8 +113,12 @@ func (i *Inferer) funDefCons(fName string, node *ast.FunDef, asMethod bool) Type
 	} else {
 		for _, expr := range termExprs {
 			retExp, isRet := expr.(*ast.ReturnExp)
+			_, isYield := expr.(*ast.YieldExp)
 			if isRet {
 				i.AddCons(retVar, i.TypeRef(retExp.Target))
+			} else if isYield {
+				// Yields constrain the return type to a coroutine when they're walked
+				continue
 			} else {
 				i.AddCons(retVar, i.TypeRef(expr))
 			}
@@ -151,6 +165,10 @@ func (i *Inferer) WalkNode(astNode ast.Node) ast.Node {
 		i.AddCons(currRef, i.StrRef())
 	case *ast.ByteExp:
 		i.AddCons(currRef, i.BaseRef(TypeBase{types.ByteType{}}))
+	case *ast.CommandExp:
+		i.AddCons(currRef, i.StrRef())
+	case *ast.InterpStr:
+		i.AddCons(currRef, i.StrRef())
 	case *ast.ArrayLiteral:
 		elemType := i.NewVar()
 		for _, elem := range node.Exprs {
@@ -162,6 +180,29 @@ func (i *Inferer) WalkNode(astNode ast.Node) ast.Node {
 	case *ast.SliceNode:
 		arrRef := i.SliceRef(currRef, i.TypeRef(node.Index))
 		i.AddCons(arrRef, i.TypeRef(node.Arr))
+	case *ast.RangeSlice:
+		intRef := i.BaseRef(TypeBase{types.IntType{}})
+		if node.Low != nil {
+			i.AddCons(i.TypeRef(node.Low), intRef)
+		}
+		if node.High != nil {
+			i.AddCons(i.TypeRef(node.High), intRef)
+		}
+		i.AddCons(currRef, i.TypeRef(node.Arr))
+	case *ast.MapLiteral:
+		keyType := i.NewVar()
+		valueType := i.NewVar()
+		for k, key := range node.Keys {
+			i.AddCons(keyType, i.TypeRef(key))
+			i.AddCons(valueType, i.TypeRef(node.Values[k]))
+		}
+
+		i.AddCons(currRef, i.MapRef(keyType, valueType))
+	case *ast.InExp:
+		boolRef := i.BaseRef(TypeBase{types.BoolType{}})
+		container := i.PartialStructRef("__in__", i.FuncRef(KindFunc, boolRef, i.TypeRef(node.Item)))
+		i.AddCons(container, i.TypeRef(node.Container))
+		i.AddCons(currRef, boolRef)
 	case *ast.AddSub:
 		i.AddCons(i.TypeRef(node.Right), i.TypeRef(node.Left))
 		i.AddCons(currRef, i.TypeRef(node.Right))
@@ -174,6 +215,23 @@ func (i *Inferer) WalkNode(astNode ast.Node) ast.Node {
 	case *ast.CompNode:
 		i.AddCons(i.TypeRef(node.Left), i.TypeRef(node.Right))
 		i.AddCons(currRef, i.BaseRef(TypeBase{types.BoolType{}}))
+	case *ast.BitOp:
+		if !node.IsShift() {
+			// Shift amounts can be any natural type, the other operators need matching operands
+			i.AddCons(i.TypeRef(node.Left), i.TypeRef(node.Right))
+		}
+		i.AddCons(currRef, i.TypeRef(node.Left))
+	case *ast.Complement:
+		i.AddCons(currRef, i.TypeRef(node.Target))
+	case *ast.AndOr:
+		boolRef := i.BaseRef(TypeBase{types.BoolType{}})
+		i.AddCons(i.TypeRef(node.Left), boolRef)
+		i.AddCons(i.TypeRef(node.Right), boolRef)
+		i.AddCons(currRef, boolRef)
+	case *ast.Not:
+		boolRef := i.BaseRef(TypeBase{types.BoolType{}})
+		i.AddCons(i.TypeRef(node.Target), boolRef)
+		i.AddCons(currRef, boolRef)
 	case *ast.Extern:
 		identRef := i.TypeRef(&ast.Ident{node.Name, ast.NoID})
 		i.AddCons(identRef, i.typeToRef(node.Type))
@@ -182,10 +240,11 @@ func (i *Inferer) WalkNode(astNode ast.Node) ast.Node {
 	case *ast.ReturnExp:
 		// Returns are handled when walking the function definition
 	case *ast.YieldExp:
-		// If a function contains a yield, it automatically returns a coroutine object
+		// If a function contains a yield, it automatically returns a coroutine object.
+		// The yield itself evaluates to the value sent in when the coroutine is resumed.
 		currFun := i.Resolve(i.funLookup[i.currFunc]).(TypeFunc)
 
-		newCo := i.CoroRef(i.TypeRef(node.Target), i.NewVar())
+		newCo := i.CoroRef(i.TypeRef(node.Target), currRef)
 		i.AddCons(currFun.Ret, newCo)
 	case *ast.BeginExp:
 		lastItem := node.Nodes[len(node.Nodes)-1]
@@ -201,7 +260,83 @@ func (i *Inferer) WalkNode(astNode ast.Node) ast.Node {
 			i.AddCons(sourceTup, parent)
 		}
 	case *ast.StructInstance:
-		i.AddCons(currRef, i.StructRef(node.DefRef))
+		structRef := i.StructRef(node.DefRef)
+		i.AddCons(currRef, structRef)
+		if node.DefRef.Generic() {
+			// The member values decide the type arguments
+			props := i.Resolve(i.Resolve(structRef).(TypeFunc).Args[0]).(FuncMeta).data.(map[string]TypeRef)
+			for k, member := range node.DefRef.Members {
+				i.AddCons(i.TypeRef(node.Values[k]), props[member.Name.Value])
+			}
+		}
+	case *ast.EnumInstance:
+		i.AddCons(currRef, i.BaseRef(TypeBase{node.DefRef.Type}))
+	case *ast.InterfaceValue:
+		// The struct being converted needs every method of the interface, at the same type
+		ifaceRef := i.InterfaceRef(i.prog.Interface(node.Type.Name))
+		i.AddCons(currRef, ifaceRef)
+		props := i.Resolve(i.Resolve(ifaceRef).(TypeFunc).Args[0]).(FuncMeta).data.(map[string]TypeRef)
+		for name, methodRef := range props {
+			i.AddCons(i.TypeRef(node.Value), i.PartialStructRef(name, methodRef))
+		}
+	case *ast.Match:
+		// Every pattern matches values of the target's type
+		for _, arm := range node.Arms {
+			i.AddCons(i.TypeRef(arm.Pattern), i.TypeRef(node.Target))
+			if arm.Guard != nil {
+				i.AddCons(i.TypeRef(arm.Guard), i.BaseRef(TypeBase{types.BoolType{}}))
+			}
+		}
+		if node.HasValue() {
+			for _, arm := range node.Arms {
+				i.AddCons(currRef, i.TypeRef(arm.Body.Lines[len(arm.Body.Lines)-1]))
+			}
+		}
+	case *ast.VariantPattern:
+		enumDef := i.prog.VariantEnum(node.Variant)
+		if enumDef == nil {
+			errs.Error(errs.ErrorValue, node, "unknown enum variant '%s'", node.Variant)
+			errs.CheckExit()
+		}
+		variant := enumDef.Variant(node.Variant)
+		if len(node.Patterns) != len(variant.Types) {
+			errs.Error(errs.ErrorType, node, "variant '%s' has %d values, but the pattern has %d", variant.Name, len(variant.Types), len(node.Patterns))
+			errs.CheckExit()
+		}
+
+		i.AddCons(currRef, i.BaseRef(TypeBase{enumDef.Type}))
+		for k, pattern := range node.Patterns {
+			i.AddCons(i.TypeRef(pattern), i.typeToRef(variant.Types[k]))
+		}
+	case *ast.TuplePattern:
+		// Each element is matched like a tuple access, so the elements get the types of the target's elements
+		for k, elem := range node.Elems {
+			i.AddCons(currRef, i.PartialTupleRef(k, i.TypeRef(elem)))
+		}
+	case *ast.StructPattern:
+		structDef := i.prog.Struct(node.Name)
+		if structDef == nil {
+			errs.Error(errs.ErrorValue, node, "unknown struct '%s'", node.Name)
+			errs.CheckExit()
+		}
+
+		if !structDef.Generic() {
+			i.AddCons(currRef, i.StructRef(structDef))
+		}
+		for k, field := range node.Fields {
+			if !structDef.HasMember(field) {
+				errs.Error(errs.ErrorValue, node, "struct '%s' has no member '%s'", node.Name, field)
+				errs.CheckExit()
+			}
+			if structDef.Generic() {
+				// The member types depend on which instance of the struct is matched
+				i.AddCons(currRef, i.PartialStructRef(field, i.TypeRef(node.Patterns[k])))
+			} else {
+				i.AddCons(i.TypeRef(node.Patterns[k]), i.typeToRef(structDef.MemberType(field)))
+			}
+		}
+	case *ast.TypePattern:
+		i.AddCons(i.TypeRef(node.Bind), i.typeToRef(node.Type))
 	case *ast.StructAccess:
 		target := i.TypeRef(node.Target)
 		propName := node.Field.(*ast.Ident).Value
@@ -221,7 +356,20 @@ func (i *Inferer) WalkNode(astNode ast.Node) ast.Node {
 		i.AddCons(i.TypeRef(node.Cond), i.BaseRef(TypeBase{types.BoolType{}}))
 	case *ast.For:
 		i.AddCons(i.TypeRef(node.Cond), i.BaseRef(TypeBase{types.BoolType{}}))
+	case *ast.TryCatch:
+		// Errors are caught as their message
+		i.AddCons(i.TypeRef(node.Err), i.StrRef())
+	case *ast.Throw:
+		i.AddCons(i.TypeRef(node.Value), i.StrRef())
 	case *ast.ForIter:
+		pair, isPair := node.Item.(*ast.TupleLiteral)
+		if isPair {
+			// Destructured items can only come from iterating over a map
+			sourceMap := i.MapRef(i.TypeRef(pair.Exprs[0]), i.TypeRef(pair.Exprs[1]))
+			i.AddCons(i.TypeRef(node.Iter), sourceMap)
+			break
+		}
+
 		sourceCoro := i.CoroRef(i.TypeRef(node.Item), i.NewVar())
 		i.AddCons(i.TypeRef(node.Iter), sourceCoro)
 	case *ast.Pipeline:
@@ -244,8 +392,18 @@ func (i *Inferer) WalkNode(astNode ast.Node) ast.Node {
 		}
 
 		i.AddCons(currRef, i.ArrRef(lastRet))
-	case *ast.NullExp:
 	case *ast.If:
+		i.AddCons(i.TypeRef(node.Cond), i.BaseRef(TypeBase{types.BoolType{}}))
+		if node.HasValue() && i.prog.Discarded(node) {
+			// The if is only used as a statement, so its branches can produce different types
+			i.AddCons(currRef, i.BaseRef(TypeBase{types.VoidType{}}))
+		} else if node.HasValue() {
+			// Every branch must produce the same type as the if itself
+			for _, branch := range node.Branches() {
+				i.AddCons(currRef, i.TypeRef(branch.Lines[len(branch.Lines)-1]))
+			}
+		}
+	case *ast.NullExp:
 	case *ast.BlockExp:
 	case *ast.FlowControl:
 	default:
@@ -292,8 +450,25 @@ func (i *Inferer) typeToRef(hintType types.Type) TypeRef {
 		return i.TupleRef(args...)
 	case types.ArrayType:
 		return i.ArrRef(i.typeToRef(ty.Subtype))
+	case types.MapType:
+		return i.MapRef(i.typeToRef(ty.Key), i.typeToRef(ty.Value))
 	case types.StructType:
-		return i.StructRef(i.prog.Struct(ty.Name))
+		structDef := i.prog.Struct(ty.TypeString())
+		if structDef == nil && len(ty.Params) > 0 {
+			// The instance hasn't been set up yet, so it's a copy of the generic struct
+			return i.GenericStructRef(i.prog.Struct(ty.Name), ty.Params)
+		}
+		return i.StructRef(structDef)
+	case types.ParamType:
+		paramRef, ok := i.typeParams[ty.Name]
+		if !ok {
+			panic("unknown type parameter: " + ty.Name)
+		}
+		return paramRef
+	case types.EnumType:
+		return i.BaseRef(TypeBase{ty})
+	case types.InterfaceType:
+		return i.InterfaceRef(i.prog.Interface(ty.Name))
 	case types.StringType:
 		return i.StrRef()
 	case types.IntType, types.FloatType, types.ByteType, types.BoolType, types.VoidType, types.AnyType:
@@ -324,5 +499,26 @@ func (i *Inferer) genBuiltinConstraints(node *ast.BuiltinExp, ref TypeRef) {
 		i.AddCons(ref, i.StrRef())
 	case ast.BuiltinType:
 		i.AddCons(ref, i.BaseRef(TypeBase{types.IntType{}}))
+	case ast.BuiltinExitCode:
+		i.AddCons(ref, i.BaseRef(TypeBase{types.IntType{}}))
+	case ast.BuiltinInt:
+		i.AddCons(ref, i.BaseRef(TypeBase{types.IntType{}}))
+	case ast.BuiltinFloat:
+		i.AddCons(ref, i.BaseRef(TypeBase{types.FloatType{}}))
+	case ast.BuiltinByte:
+		i.AddCons(ref, i.BaseRef(TypeBase{types.ByteType{}}))
+	case ast.BuiltinParseInt:
+		i.AddCons(i.TypeRef(node.Args[0]), i.StrRef())
+		i.AddCons(ref, i.TupleRef(i.BaseRef(TypeBase{types.IntType{}}), i.BaseRef(TypeBase{types.BoolType{}})))
+	case ast.BuiltinParseFloat:
+		i.AddCons(i.TypeRef(node.Args[0]), i.StrRef())
+		i.AddCons(ref, i.TupleRef(i.BaseRef(TypeBase{types.FloatType{}}), i.BaseRef(TypeBase{types.BoolType{}})))
+	case ast.BuiltinPrint:
+		i.AddCons(ref, i.BaseRef(TypeBase{types.VoidType{}}))
+	case ast.BuiltinArgv:
+		i.AddCons(ref, i.ArrRef(i.StrRef()))
+	case ast.BuiltinEnv:
+		i.AddCons(i.TypeRef(node.Args[0]), i.StrRef())
+		i.AddCons(ref, i.StrRef())
 	}
 }
\ No newline at end of file
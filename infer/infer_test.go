@@ -2,6 +2,8 @@ package infer
 
 import (
 	"dandelion/ast"
+	"dandelion/parser"
+	"dandelion/transform"
 	"dandelion/types"
 	"fmt"
 	"strings"
@@ -216,4 +218,109 @@ func stringsEqual(strs... string) bool {
 	}
 
 	return true
-}
\ No newline at end of file
+}
+func specializeSource(src string) (*ast.Program, *captureError) {
+	prog := parser.ParseProgram(src)
+	transform.TransformAst(prog)
+	return prog, specializeGenerics(prog)
+}
+
+func TestSpecializeUses(t *testing.T) {
+	src := `
+ident = f(x) { x }
+print(ident(1), ident("a"), ident(2))
+`
+
+	prog, err := specializeSource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copies := 0
+	for name := range prog.Funcs {
+		if strings.HasPrefix(name, "ident") {
+			copies++
+		}
+	}
+	if copies != 2 {
+		t.Errorf("expected a copy of ident for int and string, got %d copies", copies)
+	}
+}
+
+func TestSpecializeTypeError(t *testing.T) {
+	src := `
+ident = f(x) { x }
+print(ident(1), ident("a") + 1)
+`
+
+	prog, err := specializeSource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Regular inference reports the error, so the program is left alone
+	if strings.Contains(prog.Funcs["main"].String(), ".use") || len(prog.Funcs) != 2 {
+		t.Errorf("expected the program to be unchanged:\n%s", prog.Funcs["main"])
+	}
+}
+
+func TestCaptureAtTwoTypes(t *testing.T) {
+	src := `
+y = 1
+g = f(x) {
+	print(y)
+	x
+}
+print(g(5), g("s"))
+`
+
+	_, err := specializeSource(src)
+	expected := "can't use 'g' at more than one type, since it captures 'y'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestCaptureAtOneType(t *testing.T) {
+	src := `
+y = 1
+g = f(x) { x + y }
+print(g(5), g(6))
+`
+
+	_, err := specializeSource(src)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenericUsesGeneric(t *testing.T) {
+	src := `
+ident = f(x) { x }
+g = f(x) { ident(x) }
+print(ident(1), ident("a"))
+print(g(5), g("s"))
+`
+
+	_, err := specializeSource(src)
+	expected := "can't use 'g' at more than one type, since it captures 'ident'"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestGenericInCapture(t *testing.T) {
+	src := `
+ident = f(x) { x }
+show = f() {
+	print(ident(7), ident("seven"))
+}
+show()
+`
+
+	_, err := specializeSource(src)
+	expected := "can't use 'ident' at more than one type in 'show', since 'show' captures it"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
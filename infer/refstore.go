@@ -2,6 +2,7 @@ package infer
 
 import (
 	"dandelion/ast"
+	"dandelion/errs"
 	"dandelion/types"
 	"fmt"
 	"reflect"
@@ -105,34 +106,83 @@ func (i *Inferer) SliceRef(sliceRef TypeRef, argRef TypeRef) TypeRef {
 
 func (i *Inferer) ArrRef(subtype TypeRef) TypeRef {
 	props := make(map[string]TypeRef)
-	props["push"] = i.FuncRef(KindFunc, i.BaseRef(TypeBase{types.VoidType{}}), subtype)
-	props["__slice__"] = i.FuncRef(KindFunc, subtype, i.BaseRef(TypeBase{types.IntType{}}))
+	voidRef := i.BaseRef(TypeBase{types.VoidType{}})
+	intRef := i.BaseRef(TypeBase{types.IntType{}})
+	props["push"] = i.FuncRef(KindFunc, voidRef, subtype)
+	props["pop"] = i.FuncRef(KindFunc, subtype)
+	props["insert"] = i.FuncRef(KindFunc, voidRef, intRef, subtype)
+	props["remove"] = i.FuncRef(KindFunc, subtype, intRef)
+	props["reverse"] = i.FuncRef(KindFunc, voidRef)
+	props["clear"] = i.FuncRef(KindFunc, voidRef)
+	props["index"] = i.FuncRef(KindFunc, intRef, subtype)
+	props["__slice__"] = i.FuncRef(KindFunc, subtype, intRef)
 
 	arrRef := i.FuncRef(KindStructInstance, i.FuncMeta(ArrStruct), i.FuncMeta(props), subtype)
+	// Lists can only be extended by lists of the same type
+	props["extend"] = i.FuncRef(KindFunc, voidRef, arrRef)
+
 	return arrRef
 }
 
 func (i *Inferer) StrRef() TypeRef {
 	props := make(map[string]TypeRef)
-	props["__slice__"] = i.FuncRef(KindFunc, i.BaseRef(TypeBase{types.ByteType{}}), i.BaseRef(TypeBase{types.IntType{}}))
+	intRef := i.BaseRef(TypeBase{types.IntType{}})
+	boolRef := i.BaseRef(TypeBase{types.BoolType{}})
+	props["__slice__"] = i.FuncRef(KindFunc, i.BaseRef(TypeBase{types.ByteType{}}), intRef)
 
 	strRef := i.FuncRef(KindStructInstance, i.FuncMeta(StrStruct), i.FuncMeta(props))
+
+	// Methods are added after the ref exists because most of them take or return strings
+	props["split"] = i.FuncRef(KindFunc, i.ArrRef(strRef), strRef)
+	props["join"] = i.FuncRef(KindFunc, strRef, i.ArrRef(strRef))
+	props["trim"] = i.FuncRef(KindFunc, strRef)
+	props["find"] = i.FuncRef(KindFunc, intRef, strRef)
+	props["contains"] = i.FuncRef(KindFunc, boolRef, strRef)
+	props["replace"] = i.FuncRef(KindFunc, strRef, strRef, strRef)
+	props["startswith"] = i.FuncRef(KindFunc, boolRef, strRef)
+	props["endswith"] = i.FuncRef(KindFunc, boolRef, strRef)
+	props["upper"] = i.FuncRef(KindFunc, strRef)
+	props["lower"] = i.FuncRef(KindFunc, strRef)
+	props["repeat"] = i.FuncRef(KindFunc, strRef, intRef)
+
 	return strRef
 }
 
+func (i *Inferer) MapRef(key TypeRef, value TypeRef) TypeRef {
+	props := make(map[string]TypeRef)
+	props["delete"] = i.FuncRef(KindFunc, i.BaseRef(TypeBase{types.VoidType{}}), key)
+	props["__slice__"] = i.FuncRef(KindFunc, value, key)
+	props["__in__"] = i.FuncRef(KindFunc, i.BaseRef(TypeBase{types.BoolType{}}), key)
+
+	mapRef := i.FuncRef(KindStructInstance, i.FuncMeta(MapStruct), i.FuncMeta(props), key, value)
+	return mapRef
+}
+
 func (i *Inferer) StructRef(def *ast.StructDef) TypeRef {
 	oldRef, ok := i.structRefs[def]
 	if ok {
 		return oldRef
 	}
 
+	// A generic struct's type parameters are stored after its definition, like an array's subtype.
+	// This ref is the one its methods are inferred with. Each instance of it gets a copy.
+	paramRefs := make(map[string]TypeRef)
+	params := make([]TypeRef, len(def.Params))
+	for k, param := range def.Params {
+		params[k] = i.NewVar()
+		paramRefs[param] = params[k]
+	}
+
 	props := make(map[string]TypeRef)
-	structFun := i.FuncRef(KindStructInstance, i.FuncMeta(WholeStruct), i.FuncMeta(props), i.FuncMeta(def))
+	structFun := i.FuncRef(KindStructInstance, i.FuncMeta(WholeStruct), append([]TypeRef{i.FuncMeta(props), i.FuncMeta(def)}, params...)...)
 	i.structRefs[def] = structFun
 
+	outerParams := i.typeParams
+	i.typeParams = paramRefs
 	for _, member := range def.Members {
 		props[member.Name.Value] = i.typeToRef(member.Type)
 	}
+	i.typeParams = outerParams
 
 	for _, method := range def.Methods {
 		// Exclude the first arg because that's the "this" argument
@@ -144,6 +194,45 @@ func (i *Inferer) StructRef(def *ast.StructDef) TypeRef {
 	return structFun
 }
 
+// InterfaceRef creates the type of an interface value. Its properties are the interface's methods.
+func (i *Inferer) InterfaceRef(def *ast.InterfaceDef) TypeRef {
+	oldRef, ok := i.ifaceRefs[def]
+	if ok {
+		return oldRef
+	}
+
+	props := make(map[string]TypeRef)
+	ifaceFun := i.FuncRef(KindStructInstance, i.FuncMeta(InterfaceStruct), i.FuncMeta(props), i.FuncMeta(def))
+	i.ifaceRefs[def] = ifaceFun
+
+	for _, method := range def.Methods {
+		_, isFunc := method.Type.(types.FuncType)
+		if !isFunc {
+			errs.Error(errs.ErrorType, method, "interface method '%s' must have a function type", method.Name.Value)
+			errs.CheckExit()
+		}
+		props[method.Name.Value] = i.typeToRef(method.Type)
+	}
+
+	return ifaceFun
+}
+
+// GenericStructRef creates the type of an instance of a generic struct, like Stack[int]
+func (i *Inferer) GenericStructRef(def *ast.StructDef, args []types.Type) TypeRef {
+	structRef := i.StructRef(def)
+	structFun := i.Resolve(structRef).(TypeFunc)
+
+	inst := newInstantiator(i)
+	for k, param := range structFun.Args[2:] {
+		paramVar, isVar := i.Resolve(param).(TypeVar)
+		if isVar {
+			inst.vars[paramVar] = i.typeToRef(args[k])
+		}
+	}
+
+	return inst.instantiate(structRef)
+}
+
 func (i *Inferer) PartialStructRef(propName string, propRef TypeRef) TypeRef {
 	props := make(map[string]TypeRef)
 	props[propName] = propRef
@@ -2,6 +2,7 @@ package infer
 
 import (
 	"dandelion/ast"
+	"dandelion/errs"
 	"dandelion/transform"
 	"dandelion/types"
 	"errors"
@@ -57,7 +58,7 @@ func (r *Resolver) resolve(nodeRef TypeRef) (types.Type, error) {
 			tupType := types.TupleType{}
 			wholeTup := r.i.Resolve(ty.Ret).(FuncMeta).data.(int)
 			if wholeTup != WholeTuple {
-				panic("partial tuple left after inference")
+				return nil, errors.New("partial tuple left after inference")
 			}
 
 			tupElems := r.i.Resolve(ty.Args[0]).(FuncMeta).data.(map[int]TypeRef)
@@ -74,10 +75,18 @@ func (r *Resolver) resolve(nodeRef TypeRef) (types.Type, error) {
 		case KindStructInstance:
 			structType := r.i.Resolve(ty.Ret).(FuncMeta).data.(int)
 			if structType == PartialStruct {
-				panic("partial struct left after inference")
+				return nil, errors.New("partial struct left after inference")
 			}
 			if structType == WholeStruct {
-				structType := r.i.Resolve(ty.Args[1]).(FuncMeta).data.(*ast.StructDef).Type
+				structDef := r.i.Resolve(ty.Args[1]).(FuncMeta).data.(*ast.StructDef)
+				structType := structDef.Type
+				for _, param := range ty.Args[2:] {
+					paramType, err := r.resolve(param)
+					if err != nil {
+						return nil, fmt.Errorf("%s type argument: %w", structDef.Type.Name, err)
+					}
+					structType.Params = append(structType.Params, paramType)
+				}
 				retType = structType
 			} else if structType == ArrStruct {
 				arrSubtype, err := r.resolve(ty.Args[1])
@@ -87,6 +96,18 @@ func (r *Resolver) resolve(nodeRef TypeRef) (types.Type, error) {
 				retType = types.ArrayType{arrSubtype}
 			} else if structType == StrStruct {
 				retType = types.StringType{}
+			} else if structType == InterfaceStruct {
+				retType = r.i.Resolve(ty.Args[1]).(FuncMeta).data.(*ast.InterfaceDef).Type
+			} else if structType == MapStruct {
+				keyType, err := r.resolve(ty.Args[1])
+				if err != nil {
+					return nil, fmt.Errorf("unknown map key type: %s", r.i.String(nodeRef))
+				}
+				valueType, err := r.resolve(ty.Args[2])
+				if err != nil {
+					return nil, fmt.Errorf("unknown map value type: %s", r.i.String(nodeRef))
+				}
+				retType = types.MapType{keyType, valueType}
 			} else {
 				panic("unknown partial struct type during resolution")
 			}
@@ -97,8 +118,13 @@ func (r *Resolver) resolve(nodeRef TypeRef) (types.Type, error) {
 			}
 			gets, err := r.resolve(ty.Ret)
 			if err != nil {
-				// Can't detect the send type, just use int
+				// Can't detect the send type, just use int. It's stored so the coroutine's yields, which
+				// evaluate to what's sent, resolve to it too when their values aren't used.
 				gets = types.IntType{}
+				_, isVar := r.i.Resolve(ty.Ret).(TypeVar)
+				if isVar {
+					r.i.SetRef(ty.Ret, r.i.BaseRef(TypeBase{gets}))
+				}
 			}
 			coroType := types.CoroutineType{yields, gets}
 			retType = coroType
@@ -136,7 +162,11 @@ func (r *Resolver) WalkNode(astNode ast.Node) ast.Node {
 
 	_, isBegin := astNode.(*ast.BeginExp)
 	_, isFunApp := astNode.(*ast.FunApp)
-	if types.Equals(nodeType, types.VoidType{}) && !ast.Statement(astNode) && !isBegin && !isFunApp && !transform.IsCloArg(astNode) {
+	_, isIf := astNode.(*ast.If)
+	// Like function calls, send and print have no value but can be used as statements
+	builtin, isBuiltin := astNode.(*ast.BuiltinExp)
+	isVoidBuiltin := isBuiltin && (builtin.Type == ast.BuiltinSend || builtin.Type == ast.BuiltinPrint)
+	if types.Equals(nodeType, types.VoidType{}) && !ast.Statement(astNode) && !isBegin && !isFunApp && !isIf && !isVoidBuiltin && !transform.IsCloArg(astNode) {
 		panic("invalid void expression: " + astNode.String())
 	}
 	r.ResolvedTypes[hash] = nodeType
@@ -152,9 +182,34 @@ func (r *Resolver) WalkBlock(block *ast.Block) *ast.Block {
 func Resolve(prog *ast.Program, i *Inferer) map[ast.NodeHash]types.Type {
 	r := NewResolver(i)
 
+	for _, fun := range prog.Funcs {
+		r.checkYields(fun)
+	}
 	for _, fun := range prog.Funcs {
 		ast.WalkAst(fun, r)
 	}
 
 	return r.ResolvedTypes
-}
\ No newline at end of file
+}
+
+// checkYields reports the yields whose values are used when nothing is sent to them. This is checked
+// before anything else is resolved, because whatever the value is assigned to can't be resolved either.
+func (r *Resolver) checkYields(fun *ast.FunDef) {
+	ast.WalkAst(fun, &ast.BaseWalker{
+		WalkN: func(astNode ast.Node) ast.Node {
+			yield, isYield := astNode.(*ast.YieldExp)
+			if !isYield || r.i.prog.Discarded(yield) {
+				return nil
+			}
+			_, err := r.resolve(r.i.TypeRef(yield))
+			if err != nil {
+				errs.Error(errs.ErrorType, yield, "can't infer the type of values sent to yield")
+			}
+			return nil
+		},
+		WalkB: func(block *ast.Block) *ast.Block {
+			return nil
+		},
+	})
+	errs.CheckExit()
+}
@@ -65,6 +65,8 @@ const (
 	WholeStruct = 2
 	ArrStruct = 3
 	StrStruct = 4
+	MapStruct = 5
+	InterfaceStruct = 6
 )
 
 type FuncKind string
@@ -11,17 +11,27 @@ type Unifier struct {
 }
 
 func Unify(i *Inferer) {
+	err := unifyFrom(i, 0)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// unifyFrom unifies the constraints added since start, the ones before it must already be unified
+func unifyFrom(i *Inferer, start int) error {
 	u := &Unifier{}
 	u.i = i
 
-	for k := 0; k < len(u.i.cons); k++ {
+	for k := start; k < len(u.i.cons); k++ {
 		err := u.unify(u.i.cons[k])
 		fmt.Println("--- CONS ---")
 		i.printCons()
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
+
+	return nil
 }
 
 func swap(con *TCons) *TCons {
@@ -75,6 +85,12 @@ func (u *Unifier) unify(con *TCons) error {
 		if leftFunc.Kind == KindContainer && rightFunc.Kind == KindCoro {
 			u.i.AddCons(leftFunc.Ret, rightFunc.Args[0])
 		}
+		if leftFunc.Kind == KindContainer && rightFunc.Kind == KindStructInstance {
+			// Array literals are struct instances with the element type as their argument
+			if u.i.Resolve(rightFunc.Ret).(FuncMeta).data.(int) == ArrStruct {
+				u.i.AddCons(leftFunc.Ret, rightFunc.Args[1])
+			}
+		}
 
 		// Unify partial tuples (aka tuple accesses) and normal tuples such that
 		// tuple accesses are overwritten by tuples
@@ -131,13 +147,13 @@ func (u *Unifier) unify(con *TCons) error {
 			if leftType == PartialStruct && rightType != PartialStruct {
 				return u.unify(swap(con))
 			}
-			if (leftType == WholeStruct || leftType == ArrStruct || leftType == StrStruct) && rightType == PartialStruct {
+			if (leftType == WholeStruct || leftType == ArrStruct || leftType == StrStruct || leftType == MapStruct || leftType == InterfaceStruct) && rightType == PartialStruct {
 				partialProps := u.i.Resolve(rightFunc.Args[0]).(FuncMeta).data.(map[string]TypeRef)
 				wholeProps := u.i.Resolve(leftFunc.Args[0]).(FuncMeta).data.(map[string]TypeRef)
 				for propName, propValue := range partialProps {
 					wholeProp, ok := wholeProps[propName]
 					if !ok {
-						panic("property doesn't belong to struct")
+						return fmt.Errorf("no property '%s' in %s", propName, u.i.String(leftFunc))
 					}
 					u.i.AddCons(propValue, wholeProp)
 				}
@@ -174,10 +190,29 @@ func (u *Unifier) unify(con *TCons) error {
 				u.i.SetRef(con.Left, con.Right)
 				return nil
 			}
+			if leftType == MapStruct && rightType == MapStruct {
+				u.i.AddCons(leftFunc.Args[1], rightFunc.Args[1])
+				u.i.AddCons(leftFunc.Args[2], rightFunc.Args[2])
+				u.i.SetRef(con.Left, con.Right)
+				return nil
+			}
 			if leftType == StrStruct && rightType == StrStruct {
 				return nil
 			}
+			if leftType == InterfaceStruct || rightType == InterfaceStruct {
+				// Structs have to be converted to interfaces explicitly
+				if leftType != rightType || u.i.Resolve(leftFunc.Args[1]) != u.i.Resolve(rightFunc.Args[1]) {
+					return fmt.Errorf("%s != %s", u.i.String(leftFunc), u.i.String(rightFunc))
+				}
+				return nil
+			}
 			if leftType == WholeStruct && rightType == WholeStruct {
+				// Instances of the same generic struct have the same type arguments
+				if len(leftFunc.Args) > 2 && len(leftFunc.Args) == len(rightFunc.Args) {
+					for k := 2; k < len(leftFunc.Args); k++ {
+						u.i.AddCons(leftFunc.Args[k], rightFunc.Args[k])
+					}
+				}
 				return nil
 			}
 			panic(fmt.Sprintf("struct instance case unhandled: %d %d", leftType, rightType))
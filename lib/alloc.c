@@ -2,11 +2,11 @@
 #define ALLOC
 
 #include <stdlib.h>
-#include <fcntl.h>
 #include <stdint.h>
-#include <alloca.h>
+#include <inttypes.h>
 #include <string.h>
 #include <unistd.h>
+#include "runtime.h"
 
 #define MEM_SIZE 72
 
@@ -32,10 +32,10 @@ void* alloc_clo() {
 #endif
 #endif
 
-int zero = 0;
+int64_t zero = 0;
 
-void print(int d) {
-	printf("%d\n", d);
+void print(int64_t d) {
+	printf("%" PRId64 "\n", d);
 }
 
 void printb(char b) {
@@ -53,28 +53,6 @@ void printp(void* p) {
 	printf("%p\n", p);
 }
 
-typedef struct str {
-	uint64_t len;
-	char* data;
-} str;
-
-typedef struct arr {
-	uint32_t len;
-	uint32_t cap;
-	char* data;
-} arr;
-
 void prints(str* s) {
 	printf("%.*s\n", (int)s->len, s->data);
 }
-
-int d_open(str* fname) {
-	char* term_name = alloca(fname->len + 1);
-	memcpy(term_name, fname->data, fname->len);
-	term_name[fname->len] = 0;
-	return open(term_name, O_RDONLY);
-}
-
-int d_read(int fd, arr* buf) {
-	return read(fd, buf->data, buf->len);
-}
\ No newline at end of file
@@ -0,0 +1,74 @@
+#include <stdio.h>
+#include <string.h>
+#include <unistd.h>
+#include <sys/types.h>
+#include <sys/wait.h>
+#include "runtime.h"
+
+#define EXIT_NOT_FOUND 127
+#define EXIT_SIGNAL_BASE 128
+
+// Exit status of the most recently run command, following shell conventions
+static int64_t last_status = 0;
+
+// run_cmd runs the program named by the first arg, waits for it to exit and returns its stdout
+str* run_cmd(arr* args) {
+	str** words = (str**)args->data;
+	char** argv = GC_malloc((args->len + 1) * sizeof(char*));
+	for(uint64_t i = 0; i < args->len; i++) {
+		argv[i] = to_cstr(words[i]);
+	}
+	argv[args->len] = NULL;
+
+	int fds[2];
+	if(pipe(fds) != 0) {
+		last_status = -1;
+		return new_str(NULL, 0);
+	}
+
+	// Anything still buffered would otherwise be written twice, once by each process
+	fflush(stdout);
+	pid_t pid = fork();
+	if(pid < 0) {
+		close(fds[0]);
+		close(fds[1]);
+		last_status = -1;
+		return new_str(NULL, 0);
+	}
+	if(pid == 0) {
+		close(fds[0]);
+		dup2(fds[1], STDOUT_FILENO);
+		close(fds[1]);
+		execvp(argv[0], argv);
+		fprintf(stderr, "%s: command not found\n", argv[0]);
+		_exit(EXIT_NOT_FOUND);
+	}
+	close(fds[1]);
+
+	uint64_t cap = 256;
+	uint64_t len = 0;
+	char* buf = GC_malloc_atomic(cap);
+	ssize_t n;
+	while((n = read(fds[0], buf + len, cap - len)) > 0) {
+		len += n;
+		if(len == cap) {
+			cap *= 2;
+			buf = GC_realloc(buf, cap);
+		}
+	}
+	close(fds[0]);
+
+	int status;
+	waitpid(pid, &status, 0);
+	if(WIFEXITED(status)) {
+		last_status = WEXITSTATUS(status);
+	} else if(WIFSIGNALED(status)) {
+		last_status = EXIT_SIGNAL_BASE + WTERMSIG(status);
+	}
+
+	return new_str(buf, len);
+}
+
+int64_t cmd_status() {
+	return last_status;
+}
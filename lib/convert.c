@@ -0,0 +1,88 @@
+#include <ctype.h>
+#include <errno.h>
+#include <stdlib.h>
+#include <string.h>
+#include "runtime.h"
+
+// Large enough for any number that fits in an int or float
+#define NUM_BUF_SIZE 64
+
+// to_num_str copies a string into buf as a C string without surrounding whitespace.
+// It returns 0 if the string is empty or too long to hold a number.
+static int to_num_str(str* s, char* buf) {
+	uint64_t start = 0;
+	uint64_t end = s->len;
+	while(start < end && isspace((unsigned char)s->data[start])) {
+		start++;
+	}
+	while(end > start && isspace((unsigned char)s->data[end - 1])) {
+		end--;
+	}
+
+	if(start == end || end - start >= NUM_BUF_SIZE) {
+		return 0;
+	}
+
+	memcpy(buf, s->data + start, end - start);
+	buf[end - start] = 0;
+	return 1;
+}
+
+// parse_int parses a base 10 int, setting ok to whether the whole string was a valid int
+int64_t parse_int(str* s, int32_t* ok) {
+	char buf[NUM_BUF_SIZE];
+	*ok = 0;
+	if(!to_num_str(s, buf)) {
+		return 0;
+	}
+
+	char* end;
+	errno = 0;
+	int64_t n = strtoll(buf, &end, 10);
+	if(*end != 0 || errno == ERANGE) {
+		return 0;
+	}
+
+	*ok = 1;
+	return n;
+}
+
+// parse_float parses a float, setting ok to whether the whole string was a valid float
+double parse_float(str* s, int32_t* ok) {
+	char buf[NUM_BUF_SIZE];
+	*ok = 0;
+	if(!to_num_str(s, buf)) {
+		return 0;
+	}
+
+	char* end;
+	errno = 0;
+	double f = strtod(buf, &end);
+	if(*end != 0 || errno == ERANGE) {
+		return 0;
+	}
+
+	*ok = 1;
+	return f;
+}
+
+// str_int and str_float convert strings for the int and float builtins, which fail on invalid input
+int64_t str_int(str* s) {
+	int32_t ok;
+	int64_t n = parse_int(s, &ok);
+	if(!ok) {
+		converror(s, "int");
+	}
+
+	return n;
+}
+
+double str_float(str* s) {
+	int32_t ok;
+	double f = parse_float(s, &ok);
+	if(!ok) {
+		converror(s, "float");
+	}
+
+	return f;
+}
@@ -1,9 +1,69 @@
 #include <stdlib.h>
 #include <stdio.h>
+#include <stdint.h>
+#include <stdarg.h>
+#include <inttypes.h>
+#include <setjmp.h>
+#include "runtime.h"
 
 #define EX_INVALID_CAST_NO 1
-const char* EX_INVALID_CAST = "Fatal error: invalid assertion from any type";
-const char* EX_INDEX_OOB = "Fatal error: index %d out of bounds\n";
+const char* EX_INVALID_CAST = "invalid assertion from any type";
+const char* EX_INDEX_OOB = "index %" PRId64 " out of bounds";
+const char* EX_KEY_MISSING = "key not found in map";
+const char* EX_CONVERT = "can't convert \"%.*s\" to %s";
+const char* EX_SLICE_OOB = "slice [%" PRId64 ":%" PRId64 "] out of bounds for length %" PRIu64;
+
+// Each try block pushes a handler, which errors jump back to. Without a handler, errors end the program.
+typedef struct handler {
+	jmp_buf env;
+	struct handler* prev;
+} handler;
+
+static handler* handlers = NULL;
+static str* caught = NULL;
+
+// try_push returns the jmp_buf that the try block passes to setjmp
+void* try_push() {
+	handler* h = GC_malloc(sizeof(handler));
+	h->prev = handlers;
+	handlers = h;
+	return h->env;
+}
+
+void try_pop() {
+	handlers = handlers->prev;
+}
+
+// catch_error returns the error that jumped to the innermost handler, which has already been popped
+str* catch_error() {
+	return caught;
+}
+
+void throw_error(str* msg) {
+	if(handlers == NULL) {
+		printf("Fatal error: %.*s\n", (int)msg->len, msg->data);
+		exit(2);
+	}
+
+	handler* h = handlers;
+	handlers = h->prev;
+	caught = msg;
+	longjmp(h->env, 1);
+}
+
+void throw_fmt(const char* fmt, ...) {
+	va_list args;
+	va_start(args, fmt);
+	int len = vsnprintf(NULL, 0, fmt, args);
+	va_end(args);
+
+	char* data = GC_malloc_atomic(len + 1);
+	va_start(args, fmt);
+	vsnprintf(data, len + 1, fmt, args);
+	va_end(args);
+
+	throw_error(new_str(data, len));
+}
 
 void throwex(int exno) {
 	const char* ex_text = NULL;
@@ -14,11 +74,21 @@ void throwex(int exno) {
 			break;
 	}
 
-	printf("%s\n", ex_text);
-	exit(2);
+	throw_fmt("%s", ex_text);
+}
+
+void indexoob(int64_t index) {
+	throw_fmt(EX_INDEX_OOB, index);
 }
 
-void indexoob(int index) {
-	printf(EX_INDEX_OOB, index);
-	exit(2);
-}
\ No newline at end of file
+void sliceoob(int64_t low, int64_t high, uint64_t len) {
+	throw_fmt(EX_SLICE_OOB, low, high, len);
+}
+
+void converror(str* s, const char* type) {
+	throw_fmt(EX_CONVERT, (int)s->len, s->data, type);
+}
+
+void keyerror() {
+	throw_fmt("%s", EX_KEY_MISSING);
+}
@@ -0,0 +1,270 @@
+#include <stdlib.h>
+#include <stdbool.h>
+#include <string.h>
+#include <errno.h>
+#include <fcntl.h>
+#include <unistd.h>
+#include <sys/stat.h>
+#include "runtime.h"
+
+#define FILE_BUF_SIZE 8192
+
+// An open file. Reads and writes are buffered separately, and only one of the buffers has data at a time.
+typedef struct file {
+	int fd;
+	char* rbuf;
+	uint64_t rpos;
+	uint64_t rlen;
+	char* wbuf;
+	uint64_t wlen;
+} file;
+
+// Files are referred to by their descriptor, which indexes this table
+static file** files = NULL;
+static uint64_t files_cap = 0;
+
+typedef struct file_info {
+	int64_t size;
+	int64_t modified;
+	bool isdir;
+} file_info;
+
+static void file_error(const char* action, str* path) {
+	throw_fmt("can't %s '%.*s': %s", action, (int)path->len, path->data, strerror(errno));
+}
+
+static void handle_error(const char* action) {
+	throw_fmt("can't %s file: %s", action, strerror(errno));
+}
+
+static file* get_file(int64_t handle) {
+	if(handle < 0 || (uint64_t)handle >= files_cap || files[handle] == NULL) {
+		errno = EBADF;
+		handle_error("use");
+	}
+
+	return files[handle];
+}
+
+static void flush_writes(file* f) {
+	uint64_t written = 0;
+	while(written < f->wlen) {
+		ssize_t n = write(f->fd, f->wbuf + written, f->wlen - written);
+		if(n < 0) {
+			f->wlen = 0;
+			handle_error("write to");
+		}
+		written += n;
+	}
+	f->wlen = 0;
+}
+
+// drop_reads moves the file's position back to the first byte that was buffered but not read
+static void drop_reads(file* f) {
+	if(f->rpos < f->rlen) {
+		lseek(f->fd, (off_t)f->rpos - (off_t)f->rlen, SEEK_CUR);
+	}
+	f->rpos = 0;
+	f->rlen = 0;
+}
+
+// fill_reads reads more of the file into the read buffer, and returns false at the end of the file
+static bool fill_reads(file* f) {
+	if(f->rpos < f->rlen) {
+		return true;
+	}
+
+	flush_writes(f);
+	ssize_t n = read(f->fd, f->rbuf, FILE_BUF_SIZE);
+	if(n < 0) {
+		handle_error("read from");
+	}
+	f->rpos = 0;
+	f->rlen = n;
+
+	return n > 0;
+}
+
+static void flush_all() {
+	for(uint64_t i = 0; i < files_cap; i++) {
+		if(files[i] != NULL && files[i]->wlen > 0) {
+			ssize_t n = write(files[i]->fd, files[i]->wbuf, files[i]->wlen);
+			(void)n;
+		}
+	}
+}
+
+// file_open opens a file with a mode like C's fopen: r, w, a, r+, w+ or a+
+int64_t file_open(str* path, str* mode) {
+	int flags = -1;
+	char* cmode = to_cstr(mode);
+	if(strcmp(cmode, "r") == 0) {
+		flags = O_RDONLY;
+	} else if(strcmp(cmode, "w") == 0) {
+		flags = O_WRONLY | O_CREAT | O_TRUNC;
+	} else if(strcmp(cmode, "a") == 0) {
+		flags = O_WRONLY | O_CREAT | O_APPEND;
+	} else if(strcmp(cmode, "r+") == 0) {
+		flags = O_RDWR;
+	} else if(strcmp(cmode, "w+") == 0) {
+		flags = O_RDWR | O_CREAT | O_TRUNC;
+	} else if(strcmp(cmode, "a+") == 0) {
+		flags = O_RDWR | O_CREAT | O_APPEND;
+	}
+	if(flags == -1) {
+		throw_fmt("invalid file mode '%s'", cmode);
+	}
+
+	int fd = open(to_cstr(path), flags, 0666);
+	if(fd < 0) {
+		file_error("open", path);
+	}
+
+	if((uint64_t)fd >= files_cap) {
+		if(files == NULL) {
+			atexit(flush_all);
+		}
+		uint64_t new_cap = files_cap == 0 ? 16 : files_cap;
+		while(new_cap <= (uint64_t)fd) {
+			new_cap *= 2;
+		}
+		files = GC_realloc(files, new_cap * sizeof(file*));
+		memset(files + files_cap, 0, (new_cap - files_cap) * sizeof(file*));
+		files_cap = new_cap;
+	}
+
+	file* f = GC_malloc(sizeof(file));
+	f->fd = fd;
+	f->rbuf = GC_malloc_atomic(FILE_BUF_SIZE);
+	f->wbuf = GC_malloc_atomic(FILE_BUF_SIZE);
+	files[fd] = f;
+
+	return fd;
+}
+
+// file_read reads the rest of a file
+str* file_read(int64_t handle) {
+	file* f = get_file(handle);
+	uint64_t cap = FILE_BUF_SIZE;
+	uint64_t len = 0;
+	char* data = GC_malloc_atomic(cap);
+
+	while(fill_reads(f)) {
+		uint64_t avail = f->rlen - f->rpos;
+		if(len + avail > cap) {
+			while(len + avail > cap) {
+				cap *= 2;
+			}
+			data = GC_realloc(data, cap);
+		}
+		memcpy(data + len, f->rbuf + f->rpos, avail);
+		len += avail;
+		f->rpos = f->rlen;
+	}
+
+	return new_str(data, len);
+}
+
+// file_readline reads up to the next newline, which is left off the line
+str* file_readline(int64_t handle) {
+	file* f = get_file(handle);
+	uint64_t cap = 128;
+	uint64_t len = 0;
+	char* data = GC_malloc_atomic(cap);
+
+	while(fill_reads(f)) {
+		char* start = f->rbuf + f->rpos;
+		uint64_t avail = f->rlen - f->rpos;
+		char* newline = memchr(start, '\n', avail);
+		uint64_t take = newline == NULL ? avail : (uint64_t)(newline - start);
+
+		if(len + take > cap) {
+			while(len + take > cap) {
+				cap *= 2;
+			}
+			data = GC_realloc(data, cap);
+		}
+		memcpy(data + len, start, take);
+		len += take;
+		f->rpos += take;
+
+		if(newline != NULL) {
+			f->rpos++;
+			break;
+		}
+	}
+
+	return new_str(data, len);
+}
+
+// file_eof returns whether everything in a file has been read
+bool file_eof(int64_t handle) {
+	return !fill_reads(get_file(handle));
+}
+
+void file_write(int64_t handle, str* s) {
+	file* f = get_file(handle);
+	drop_reads(f);
+
+	if(f->wlen + s->len > FILE_BUF_SIZE) {
+		flush_writes(f);
+	}
+	if(s->len > FILE_BUF_SIZE) {
+		uint64_t written = 0;
+		while(written < s->len) {
+			ssize_t n = write(f->fd, s->data + written, s->len - written);
+			if(n < 0) {
+				handle_error("write to");
+			}
+			written += n;
+		}
+		return;
+	}
+
+	memcpy(f->wbuf + f->wlen, s->data, s->len);
+	f->wlen += s->len;
+}
+
+// file_seek moves to an offset from the start, the current position or the end of a file, and returns the new position
+int64_t file_seek(int64_t handle, int64_t offset, int64_t whence) {
+	file* f = get_file(handle);
+	flush_writes(f);
+	drop_reads(f);
+
+	int whences[] = {SEEK_SET, SEEK_CUR, SEEK_END};
+	if(whence < 0 || whence > 2) {
+		errno = EINVAL;
+		handle_error("seek in");
+	}
+
+	off_t pos = lseek(f->fd, offset, whences[whence]);
+	if(pos < 0) {
+		handle_error("seek in");
+	}
+
+	return pos;
+}
+
+void file_close(int64_t handle) {
+	file* f = get_file(handle);
+	flush_writes(f);
+	files[handle] = NULL;
+
+	if(close(f->fd) != 0) {
+		handle_error("close");
+	}
+}
+
+file_info* file_stat(str* path) {
+	struct stat st;
+	if(stat(to_cstr(path), &st) != 0) {
+		file_error("stat", path);
+	}
+
+	file_info* info = GC_malloc(sizeof(file_info));
+	info->size = st.st_size;
+	info->modified = st.st_mtime;
+	info->isdir = S_ISDIR(st.st_mode);
+
+	return info;
+}
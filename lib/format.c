@@ -0,0 +1,52 @@
+#include <stdio.h>
+#include <stdlib.h>
+#include <stdbool.h>
+#include <inttypes.h>
+#include <string.h>
+#include "runtime.h"
+
+// Large enough for any formatted int
+#define FMT_BUF_SIZE 32
+
+static str* fmt_str(const char* data, uint64_t len) {
+	str* s = GC_malloc(sizeof(str));
+	s->data = GC_malloc_atomic(len);
+	memcpy(s->data, data, len);
+	s->len = len;
+	return s;
+}
+
+str* fmt_int(int64_t n) {
+	char buf[FMT_BUF_SIZE];
+	int len = snprintf(buf, FMT_BUF_SIZE, "%" PRId64, n);
+	return fmt_str(buf, len);
+}
+
+// write_float writes the shortest decimal that reads back as exactly the same float
+int write_float(char* buf, double f) {
+	int len = 0;
+	for(int prec = 1; prec <= 17; prec++) {
+		len = snprintf(buf, FLOAT_BUF_SIZE, "%.*g", prec, f);
+		if(strtod(buf, NULL) == f) {
+			break;
+		}
+	}
+	return len;
+}
+
+str* fmt_float(double f) {
+	char buf[FLOAT_BUF_SIZE];
+	int len = write_float(buf, f);
+	return fmt_str(buf, len);
+}
+
+str* fmt_bool(bool b) {
+	if(b) {
+		return fmt_str("true", 4);
+	}
+	return fmt_str("false", 5);
+}
+
+str* fmt_byte(char b) {
+	return fmt_str(&b, 1);
+}
@@ -0,0 +1,85 @@
+#include <stdint.h>
+#include <string.h>
+#include "runtime.h"
+
+#define MIN_CAP 8
+
+arr* new_arr(uint64_t len, uint64_t elem_size) {
+	uint64_t cap = len > MIN_CAP ? len : MIN_CAP;
+
+	arr* a = GC_malloc(sizeof(arr));
+	a->len = len;
+	a->cap = cap;
+	a->data = GC_malloc(cap * elem_size);
+	return a;
+}
+
+// Element operations for lists. Bounds are checked by the compiler before these are called.
+
+static void reserve(arr* a, uint64_t len, uint64_t elem_size) {
+	if(len <= a->cap) {
+		return;
+	}
+
+	uint64_t cap = a->cap * 2;
+	if(cap < len) {
+		cap = len;
+	}
+	a->data = GC_realloc(a->data, cap * elem_size);
+	a->cap = cap;
+}
+
+// list_insert opens a gap at the index and returns a pointer to it
+void* list_insert(arr* a, uint64_t index, uint64_t elem_size) {
+	reserve(a, a->len + 1, elem_size);
+
+	char* slot = a->data + index * elem_size;
+	memmove(slot + elem_size, slot, (a->len - index) * elem_size);
+	a->len++;
+
+	return slot;
+}
+
+void list_remove(arr* a, uint64_t index, uint64_t elem_size) {
+	char* slot = a->data + index * elem_size;
+	memmove(slot, slot + elem_size, (a->len - index - 1) * elem_size);
+	a->len--;
+}
+
+void list_extend(arr* a, arr* other, uint64_t elem_size) {
+	// Copy the length first, extending a list with itself is allowed
+	uint64_t other_len = other->len;
+	reserve(a, a->len + other_len, elem_size);
+
+	memmove(a->data + a->len * elem_size, other->data, other_len * elem_size);
+	a->len += other_len;
+}
+
+void list_reverse(arr* a, uint64_t elem_size) {
+	char tmp[elem_size];
+	for(uint64_t i = 0; i < a->len / 2; i++) {
+		char* left = a->data + i * elem_size;
+		char* right = a->data + (a->len - i - 1) * elem_size;
+		memcpy(tmp, left, elem_size);
+		memcpy(left, right, elem_size);
+		memcpy(right, tmp, elem_size);
+	}
+}
+
+// list_index returns the index of the first element equal to the given one, or -1 if there isn't one
+int64_t list_index(arr* a, void* elem, uint64_t elem_size, int32_t kind) {
+	for(uint64_t i = 0; i < a->len; i++) {
+		char* curr = a->data + i * elem_size;
+		if(kind == KEY_STR) {
+			str* l = *(str**)curr;
+			str* r = *(str**)elem;
+			if(l->len == r->len && memcmp(l->data, r->data, l->len) == 0) {
+				return i;
+			}
+		} else if(memcmp(curr, elem, elem_size) == 0) {
+			return i;
+		}
+	}
+
+	return -1;
+}
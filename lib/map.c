@@ -0,0 +1,188 @@
+#include <stdint.h>
+#include <string.h>
+#include "runtime.h"
+
+#define SLOT_EMPTY 0
+#define SLOT_FULL 1
+#define SLOT_DELETED 2
+
+#define MIN_CAP 8
+
+// Open addressing hash table with linear probing. Keys and values are stored inline
+// in two parallel arrays, sized by the compiler for the map's key and value types.
+typedef struct map {
+	uint32_t len;
+	uint32_t used;
+	uint32_t cap;
+	uint32_t key_kind;
+	uint64_t key_size;
+	uint64_t val_size;
+	uint8_t* states;
+	char* keys;
+	char* vals;
+} map;
+
+static uint64_t hash_bytes(const char* data, uint64_t len) {
+	uint64_t hash = 14695981039346656037ULL;
+	for(uint64_t i = 0; i < len; i++) {
+		hash ^= (uint8_t)data[i];
+		hash *= 1099511628211ULL;
+	}
+
+	return hash;
+}
+
+static uint64_t hash_key(map* m, void* key) {
+	if(m->key_kind == KEY_STR) {
+		str* s = *(str**)key;
+		return hash_bytes(s->data, s->len);
+	}
+
+	return hash_bytes(key, m->key_size);
+}
+
+static int keys_equal(map* m, void* left, void* right) {
+	if(m->key_kind == KEY_STR) {
+		str* l = *(str**)left;
+		str* r = *(str**)right;
+		return l->len == r->len && memcmp(l->data, r->data, l->len) == 0;
+	}
+
+	return memcmp(left, right, m->key_size) == 0;
+}
+
+static void alloc_slots(map* m, uint32_t cap) {
+	m->cap = cap;
+	m->len = 0;
+	m->used = 0;
+	m->states = GC_malloc_atomic(cap);
+	memset(m->states, SLOT_EMPTY, cap);
+	// Keys and values can hold references, so they have to be scanned by the collector
+	m->keys = GC_malloc(cap * m->key_size);
+	m->vals = GC_malloc(cap * m->val_size);
+}
+
+// find_slot returns the slot holding the key, or -1 if the key isn't in the map
+static int32_t find_slot(map* m, void* key) {
+	uint32_t mask = m->cap - 1;
+	uint32_t slot = hash_key(m, key) & mask;
+
+	for(uint32_t probes = 0; probes < m->cap; probes++) {
+		if(m->states[slot] == SLOT_EMPTY) {
+			return -1;
+		}
+		if(m->states[slot] == SLOT_FULL && keys_equal(m, m->keys + slot * m->key_size, key)) {
+			return slot;
+		}
+		slot = (slot + 1) & mask;
+	}
+
+	return -1;
+}
+
+static void* insert_slot(map* m, void* key);
+
+static void resize(map* m, uint32_t cap) {
+	uint32_t old_cap = m->cap;
+	uint8_t* old_states = m->states;
+	char* old_keys = m->keys;
+	char* old_vals = m->vals;
+
+	alloc_slots(m, cap);
+	for(uint32_t i = 0; i < old_cap; i++) {
+		if(old_states[i] == SLOT_FULL) {
+			void* val = insert_slot(m, old_keys + i * m->key_size);
+			memcpy(val, old_vals + i * m->val_size, m->val_size);
+		}
+	}
+}
+
+// insert_slot adds the key to the map if it's missing and returns a pointer to its value
+static void* insert_slot(map* m, void* key) {
+	int32_t found = find_slot(m, key);
+	if(found >= 0) {
+		return m->vals + found * m->val_size;
+	}
+
+	// Keep the load factor, including deleted slots, under 3/4
+	if((m->used + 1) * 4 > m->cap * 3) {
+		uint32_t cap = m->len * 2 >= m->cap ? m->cap * 2 : m->cap;
+		resize(m, cap);
+	}
+
+	uint32_t mask = m->cap - 1;
+	uint32_t slot = hash_key(m, key) & mask;
+	while(m->states[slot] == SLOT_FULL) {
+		slot = (slot + 1) & mask;
+	}
+
+	if(m->states[slot] == SLOT_EMPTY) {
+		m->used++;
+	}
+	m->states[slot] = SLOT_FULL;
+	m->len++;
+	memcpy(m->keys + slot * m->key_size, key, m->key_size);
+	memset(m->vals + slot * m->val_size, 0, m->val_size);
+
+	return m->vals + slot * m->val_size;
+}
+
+map* map_new(uint64_t key_size, uint64_t val_size, int32_t key_kind) {
+	map* m = GC_malloc(sizeof(map));
+	m->key_kind = key_kind;
+	m->key_size = key_size;
+	m->val_size = val_size;
+	alloc_slots(m, MIN_CAP);
+
+	return m;
+}
+
+void* map_get(map* m, void* key) {
+	int32_t slot = find_slot(m, key);
+	if(slot < 0) {
+		keyerror();
+	}
+
+	return m->vals + slot * m->val_size;
+}
+
+void* map_set(map* m, void* key) {
+	return insert_slot(m, key);
+}
+
+int32_t map_has(map* m, void* key) {
+	return find_slot(m, key) >= 0;
+}
+
+void map_del(map* m, void* key) {
+	int32_t slot = find_slot(m, key);
+	if(slot < 0) {
+		return;
+	}
+
+	m->states[slot] = SLOT_DELETED;
+	m->len--;
+}
+
+int64_t map_len(map* m) {
+	return m->len;
+}
+
+// map_next returns the first occupied slot at or after the given one, or -1 once the map is exhausted
+int32_t map_next(map* m, int32_t slot) {
+	for(uint32_t i = slot; i < m->cap; i++) {
+		if(m->states[i] == SLOT_FULL) {
+			return i;
+		}
+	}
+
+	return -1;
+}
+
+void* map_key(map* m, int32_t slot) {
+	return m->keys + slot * m->key_size;
+}
+
+void* map_val(map* m, int32_t slot) {
+	return m->vals + slot * m->val_size;
+}
@@ -0,0 +1,55 @@
+#include <stdio.h>
+#include <stdbool.h>
+#include <inttypes.h>
+#include "runtime.h"
+
+// Writers for the print builtin, which generates a call to one of these for each part of a value
+
+void print_lit(const char* s) {
+	fputs(s, stdout);
+}
+
+void print_str(str* s) {
+	fwrite(s->data, 1, s->len, stdout);
+}
+
+// print_quoted writes a string as a literal, escaping quotes and control characters
+void print_quoted(str* s) {
+	putchar('"');
+	for(uint64_t i = 0; i < s->len; i++) {
+		switch(s->data[i]) {
+			case '"':
+				fputs("\\\"", stdout);
+				break;
+			case '\\':
+				fputs("\\\\", stdout);
+				break;
+			case '\n':
+				fputs("\\n", stdout);
+				break;
+			case '\t':
+				fputs("\\t", stdout);
+				break;
+			default:
+				putchar(s->data[i]);
+		}
+	}
+	putchar('"');
+}
+
+void print_int(int64_t n) {
+	printf("%" PRId64, n);
+}
+
+void print_float(double f) {
+	char buf[FLOAT_BUF_SIZE];
+	fwrite(buf, 1, write_float(buf, f), stdout);
+}
+
+void print_bool(bool b) {
+	fputs(b ? "true" : "false", stdout);
+}
+
+void print_byte(char b) {
+	putchar(b);
+}
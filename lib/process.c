@@ -0,0 +1,39 @@
+#include <stdlib.h>
+#include <string.h>
+#include "runtime.h"
+
+// The args the program was run with, not including the program's own name
+static int32_t nargs = 0;
+static char** args = NULL;
+
+// set_args is called at the start of main, with the args main was called with
+void set_args(int32_t argc, char** argv) {
+	if(argc > 1) {
+		nargs = argc - 1;
+		args = argv + 1;
+	}
+}
+
+// get_args returns a new list each time, so changing it doesn't change what later calls return
+arr* get_args() {
+	arr* list = new_arr(nargs, sizeof(str*));
+	str** words = (str**)list->data;
+	for(int32_t i = 0; i < nargs; i++) {
+		words[i] = new_str(args[i], strlen(args[i]));
+	}
+
+	return list;
+}
+
+// get_env returns the value of an environment variable, or an empty string if it isn't set
+str* get_env(str* name) {
+	char* val = getenv(to_cstr(name));
+	if(val == NULL) {
+		return new_str(NULL, 0);
+	}
+
+	uint64_t len = strlen(val);
+	char* data = GC_malloc_atomic(len);
+	memcpy(data, val, len);
+	return new_str(data, len);
+}
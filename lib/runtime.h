@@ -0,0 +1,40 @@
+#ifndef RUNTIME
+#define RUNTIME
+
+#include <stdint.h>
+#include <stddef.h>
+
+typedef struct str {
+	uint64_t len;
+	char* data;
+} str;
+
+typedef struct arr {
+	uint64_t len;
+	uint64_t cap;
+	char* data;
+} arr;
+
+void* GC_malloc(size_t size);
+void* GC_malloc_atomic(size_t size);
+void* GC_realloc(void* ptr, size_t size);
+
+// Values are either compared byte for byte or, for strings, by their contents
+#define KEY_RAW 0
+#define KEY_STR 1
+
+str* new_str(char* data, uint64_t len);
+arr* new_arr(uint64_t len, uint64_t elem_size);
+char* to_cstr(str* s);
+
+// Large enough for any float written by write_float
+#define FLOAT_BUF_SIZE 32
+int write_float(char* buf, double f);
+
+void keyerror();
+void sliceoob(int64_t low, int64_t high, uint64_t len);
+void converror(str* s, const char* type);
+void throw_error(str* msg);
+void throw_fmt(const char* fmt, ...);
+
+#endif
@@ -0,0 +1,28 @@
+#include <stdint.h>
+#include <string.h>
+#include "runtime.h"
+
+static void check_range(int64_t low, int64_t high, uint64_t len) {
+	if(low < 0 || high < low || (uint64_t)high > len) {
+		sliceoob(low, high, len);
+	}
+}
+
+// str_slice shares the data of the original string, which is safe because strings are immutable
+str* str_slice(str* s, int64_t low, int64_t high) {
+	check_range(low, high, s->len);
+
+	str* sliced = GC_malloc(sizeof(str));
+	sliced->len = high - low;
+	sliced->data = s->data + low;
+	return sliced;
+}
+
+// list_slice copies the elements into a new list. Lists can grow in place, so they can't share a buffer.
+arr* list_slice(arr* a, int64_t low, int64_t high, uint64_t elem_size) {
+	check_range(low, high, a->len);
+
+	arr* sliced = new_arr(high - low, elem_size);
+	memcpy(sliced->data, a->data + low * elem_size, sliced->len * elem_size);
+	return sliced;
+}
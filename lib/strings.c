@@ -0,0 +1,204 @@
+#define _GNU_SOURCE
+#include <ctype.h>
+#include <stdint.h>
+#include <string.h>
+#include "runtime.h"
+
+str* new_str(char* data, uint64_t len) {
+	str* s = GC_malloc(sizeof(str));
+	s->len = len;
+	s->data = data;
+	return s;
+}
+
+// to_cstr copies a string into a null terminated C string
+char* to_cstr(str* s) {
+	char* cstr = GC_malloc_atomic(s->len + 1);
+	memcpy(cstr, s->data, s->len);
+	cstr[s->len] = 0;
+	return cstr;
+}
+
+static str* copy_str(const char* data, uint64_t len) {
+	char* copy = GC_malloc_atomic(len);
+	memcpy(copy, data, len);
+	return new_str(copy, len);
+}
+
+static const char* find_sub(const char* data, uint64_t len, str* sub) {
+	if(sub->len == 0) {
+		return data;
+	}
+
+	return memmem(data, len, sub->data, sub->len);
+}
+
+static void push_str(arr* a, str* s) {
+	if(a->len == a->cap) {
+		a->cap *= 2;
+		a->data = GC_realloc(a->data, a->cap * sizeof(str*));
+	}
+
+	((str**)a->data)[a->len++] = s;
+}
+
+// str_split splits on every occurrence of sep, or on runs of whitespace if sep is empty.
+// Substrings share the data of the original string.
+arr* str_split(str* s, str* sep) {
+	arr* parts = new_arr(0, sizeof(str*));
+
+	if(sep->len == 0) {
+		uint64_t i = 0;
+		while(i < s->len) {
+			while(i < s->len && isspace((unsigned char)s->data[i])) {
+				i++;
+			}
+			uint64_t start = i;
+			while(i < s->len && !isspace((unsigned char)s->data[i])) {
+				i++;
+			}
+			if(i > start) {
+				push_str(parts, new_str(s->data + start, i - start));
+			}
+		}
+
+		return parts;
+	}
+
+	const char* start = s->data;
+	const char* end = s->data + s->len;
+	const char* found;
+	while((found = find_sub(start, end - start, sep)) != NULL) {
+		push_str(parts, new_str((char*)start, found - start));
+		start = found + sep->len;
+	}
+	push_str(parts, new_str((char*)start, end - start));
+
+	return parts;
+}
+
+str* str_join(str* sep, arr* parts) {
+	str** strs = (str**)parts->data;
+
+	uint64_t len = 0;
+	for(uint64_t i = 0; i < parts->len; i++) {
+		len += strs[i]->len;
+	}
+	if(parts->len > 0) {
+		len += sep->len * (parts->len - 1);
+	}
+
+	char* data = GC_malloc_atomic(len);
+	char* cursor = data;
+	for(uint64_t i = 0; i < parts->len; i++) {
+		if(i > 0) {
+			memcpy(cursor, sep->data, sep->len);
+			cursor += sep->len;
+		}
+		memcpy(cursor, strs[i]->data, strs[i]->len);
+		cursor += strs[i]->len;
+	}
+
+	return new_str(data, len);
+}
+
+str* str_trim(str* s) {
+	uint64_t start = 0;
+	uint64_t end = s->len;
+	while(start < end && isspace((unsigned char)s->data[start])) {
+		start++;
+	}
+	while(end > start && isspace((unsigned char)s->data[end - 1])) {
+		end--;
+	}
+
+	return new_str(s->data + start, end - start);
+}
+
+// str_find returns the index of the first occurrence of sub, or -1 if there isn't one
+int64_t str_find(str* s, str* sub) {
+	const char* found = find_sub(s->data, s->len, sub);
+	if(found == NULL) {
+		return -1;
+	}
+
+	return found - s->data;
+}
+
+// str_replace replaces every occurrence of old. An empty old string leaves the string unchanged.
+str* str_replace(str* s, str* old, str* new) {
+	if(old->len == 0) {
+		return s;
+	}
+
+	uint64_t count = 0;
+	const char* end = s->data + s->len;
+	const char* cursor = s->data;
+	const char* found;
+	while((found = find_sub(cursor, end - cursor, old)) != NULL) {
+		count++;
+		cursor = found + old->len;
+	}
+	if(count == 0) {
+		return s;
+	}
+
+	uint64_t len = s->len - count * old->len + count * new->len;
+	char* data = GC_malloc_atomic(len);
+	char* out = data;
+	cursor = s->data;
+	while((found = find_sub(cursor, end - cursor, old)) != NULL) {
+		memcpy(out, cursor, found - cursor);
+		out += found - cursor;
+		memcpy(out, new->data, new->len);
+		out += new->len;
+		cursor = found + old->len;
+	}
+	memcpy(out, cursor, end - cursor);
+
+	return new_str(data, len);
+}
+
+int32_t str_eq(str* left, str* right) {
+	return left->len == right->len && memcmp(left->data, right->data, left->len) == 0;
+}
+
+int32_t str_startswith(str* s, str* prefix) {
+	return prefix->len <= s->len && memcmp(s->data, prefix->data, prefix->len) == 0;
+}
+
+int32_t str_endswith(str* s, str* suffix) {
+	return suffix->len <= s->len && memcmp(s->data + s->len - suffix->len, suffix->data, suffix->len) == 0;
+}
+
+str* str_upper(str* s) {
+	str* upper = copy_str(s->data, s->len);
+	for(uint64_t i = 0; i < upper->len; i++) {
+		upper->data[i] = toupper((unsigned char)upper->data[i]);
+	}
+
+	return upper;
+}
+
+str* str_lower(str* s) {
+	str* lower = copy_str(s->data, s->len);
+	for(uint64_t i = 0; i < lower->len; i++) {
+		lower->data[i] = tolower((unsigned char)lower->data[i]);
+	}
+
+	return lower;
+}
+
+str* str_repeat(str* s, int64_t n) {
+	if(n <= 0) {
+		return new_str(NULL, 0);
+	}
+
+	uint64_t len = s->len * n;
+	char* data = GC_malloc_atomic(len);
+	for(int64_t i = 0; i < n; i++) {
+		memcpy(data + i * s->len, s->data, s->len);
+	}
+
+	return new_str(data, len);
+}
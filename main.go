@@ -52,7 +52,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	llvmIr := compile.CompileSource(string(src), optLevel)
+	llvmIr := compile.CompileSource(string(src), sourceFile, optLevel)
 	if outIR != "" {
 		err = ioutil.WriteFile(outIR, []byte(llvmIr), os.ModePerm)
 		return
@@ -67,5 +67,14 @@ func main() {
 		os.Exit(0)
 	}
 
-	syscall.Exec(binPath, []string{}, []string{})
+	// The program gets the args after the source file, and the same environment as the compiler
+	progArgs := []string{binPath}
+	if flag.NArg() > 1 {
+		progArgs = append(progArgs, flag.Args()[1:]...)
+	}
+	err = syscall.Exec(binPath, progArgs, os.Environ())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error running program:", err)
+		os.Exit(1)
+	}
 }
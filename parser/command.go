@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// splitCommand splits the body of a backtick command into words using shell style quoting.
+// Single quotes keep their contents exactly. Bare words and double quotes allow backslash
+// escapes and {expr} interpolation.
+func splitCommand(cmd string) ([][]textPiece, error) {
+	words := make([][]textPiece, 0)
+	var pieces []textPiece
+	inWord := false
+	var quote rune
+	literal := strings.Builder{}
+
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			pieces = append(pieces, textPiece{literal.String(), false})
+			literal.Reset()
+		}
+	}
+	endWord := func() {
+		flushLiteral()
+		if inWord {
+			words = append(words, pieces)
+		}
+		pieces = nil
+		inWord = false
+	}
+
+	runes := []rune(cmd)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if quote == '\'' {
+			if r == '\'' {
+				quote = 0
+			} else {
+				literal.WriteRune(r)
+			}
+			continue
+		}
+
+		switch {
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, errors.New("command ends with an escape character")
+			}
+			i++
+			literal.WriteRune(runes[i])
+			inWord = true
+		case r == '{':
+			end, err := interpEnd(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			flushLiteral()
+			pieces = append(pieces, textPiece{string(runes[i+1 : end]), true})
+			inWord = true
+			i = end
+		case r == '"':
+			if quote == '"' {
+				quote = 0
+			} else {
+				quote = '"'
+			}
+			inWord = true
+		case r == '\'' && quote == 0:
+			quote = '\''
+			inWord = true
+		case unicode.IsSpace(r) && quote == 0:
+			endWord()
+		default:
+			literal.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in command")
+	}
+	endWord()
+
+	return words, nil
+}
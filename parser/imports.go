@@ -0,0 +1,138 @@
+package parser
+
+import (
+	parser "dandelion/aparser"
+	"dandelion/ast"
+	"dandelion/prelude"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SourceExt is the extension of source files, which is left off a module's name
+const SourceExt = ".dan"
+
+// PreludePath is the directory of the prelude's modules, which isn't a real directory
+const PreludePath = "<prelude>"
+
+// moduleLoader keeps track of the modules that a program imports
+type moduleLoader struct {
+	modules map[string]*ast.Module // Modules that have already been parsed, by path
+	order   []*ast.Module          // Modules in the order they run, each one after the modules it imports
+	loading []string               // Paths of the modules being parsed, to detect import cycles
+}
+
+func newModuleLoader() *moduleLoader {
+	loader := &moduleLoader{}
+	loader.modules = make(map[string]*ast.Module)
+	loader.order = make([]*ast.Module, 0)
+	loader.loading = make([]string, 0)
+
+	return loader
+}
+
+func (l *listener) EnterImport(c *parser.ImportContext) {
+	DebugPrintln("Entering import")
+}
+
+func (l *listener) ExitImport(c *parser.ImportContext) {
+	DebugPrintln("Exiting import")
+
+	line := c.GetStart().GetLine()
+	pathText := c.GetPath().GetText()
+	module := l.importModule(pathText[1:len(pathText)-1], line)
+
+	name := module.Name
+	if c.GetName() != nil {
+		name = c.GetName().GetText()
+	}
+
+	l.nodeStack.Push(&ast.Import{module.Path, name, l.NewNodeID(line)})
+}
+
+// importModule parses the module at a path relative to the file being parsed, unless it's already been parsed.
+// A directory is a single module made up of all the source files in it.
+func (l *listener) importModule(path string, line int) *ast.Module {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.dir, path)
+	}
+	path = absPath(path)
+
+	for k, loading := range l.loader.loading {
+		if loading == path {
+			cycle := append(l.loader.loading[k:], path)
+			importError(line, "import cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	module, ok := l.loader.modules[path]
+	if ok {
+		return module
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		importError(line, "can't import '%s': %s", path, err)
+	}
+
+	files := []string{path}
+	name := strings.TrimSuffix(filepath.Base(path), SourceExt)
+	if info.IsDir() {
+		files, _ = filepath.Glob(filepath.Join(path, "*"+SourceExt))
+		name = filepath.Base(path)
+		if len(files) == 0 {
+			importError(line, "no source files in '%s'", path)
+		}
+	}
+
+	l.loader.loading = append(l.loader.loading, path)
+	body := &ast.Block{}
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			importError(line, "can't import '%s': %s", file, err)
+		}
+		body.Lines = append(body.Lines, l.parse(string(src), filepath.Dir(file), file).Lines...)
+	}
+	l.loader.loading = l.loader.loading[:len(l.loader.loading)-1]
+
+	module = &ast.Module{name, path, body, l.NewNodeID(line)}
+	l.loader.modules[path] = module
+	l.loader.order = append(l.loader.order, module)
+
+	return module
+}
+
+// parsePrelude parses the prelude, which runs before every other module. Each file of it is a module
+// named after the file, which every module can use without importing it.
+func (l *listener) parsePrelude() {
+	for _, source := range prelude.Sources {
+		path := filepath.Join(PreludePath, source.Name)
+		body := l.parse(source.Text, ".", path)
+
+		module := &ast.Module{strings.TrimSuffix(source.Name, SourceExt), path, body, l.NewNodeID(0)}
+		l.loader.modules[path] = module
+		l.loader.order = append(l.loader.order, module)
+	}
+}
+
+// IsPrelude returns whether a module path is one of the prelude's modules
+func IsPrelude(path string) bool {
+	return filepath.Dir(path) == PreludePath
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	return abs
+}
+
+func importError(line int, format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Fatal Parse Error: line %d - %s\n", line, fmt.Sprintf(format, a...))
+	os.Exit(1)
+}
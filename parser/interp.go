@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+)
+
+// textPiece is a run of literal text, or the source of an interpolated expression, inside a string or command word
+type textPiece struct {
+	text   string
+	interp bool
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'\\': '\\',
+	'"':  '"',
+	'{':  '{',
+	'}':  '}',
+}
+
+// splitInterp splits the body of a string literal into literal text and {expr} interpolations,
+// resolving escape sequences in the literal text along the way
+func splitInterp(text string) ([]textPiece, error) {
+	pieces := make([]textPiece, 0)
+	literal := strings.Builder{}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 >= len(runes) {
+				return nil, errors.New("string ends with an escape character")
+			}
+			i++
+			escaped, ok := escapes[runes[i]]
+			if !ok {
+				// Unknown escapes are kept as they were written
+				literal.WriteRune('\\')
+				escaped = runes[i]
+			}
+			literal.WriteRune(escaped)
+		case '{':
+			end, err := interpEnd(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			if literal.Len() > 0 {
+				pieces = append(pieces, textPiece{literal.String(), false})
+				literal.Reset()
+			}
+			pieces = append(pieces, textPiece{string(runes[i+1 : end]), true})
+			i = end
+		default:
+			literal.WriteRune(runes[i])
+		}
+	}
+
+	if literal.Len() > 0 {
+		pieces = append(pieces, textPiece{literal.String(), false})
+	}
+
+	return pieces, nil
+}
+
+// interpEnd finds the brace closing the interpolation that starts at start, skipping over
+// braces that are nested or inside string literals
+func interpEnd(runes []rune, start int) (int, error) {
+	depth := 0
+	inString := false
+	for i := start; i < len(runes); i++ {
+		switch {
+		case inString && runes[i] == '\\':
+			i++
+		case runes[i] == '"':
+			inString = !inString
+		case inString:
+		case runes[i] == '{':
+			depth++
+		case runes[i] == '}':
+			depth--
+			if depth == 0 {
+				if i == start+1 {
+					return 0, errors.New("empty interpolation")
+				}
+				return i, nil
+			}
+		}
+	}
+
+	return 0, errors.New("unterminated interpolation")
+}
@@ -4,9 +4,11 @@ import (
 	parser "dandelion/aparser"
 	"dandelion/ast"
 	"dandelion/types"
+	"errors"
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -24,7 +26,17 @@ type listener struct {
 	nodeID     ast.NodeID
 	emptyArrNo int
 	nullNo     int
+	lineOffset int
+	tokens     *antlr.CommonTokenStream
 	prog       *ast.Program
+	enumNames  map[string]bool
+	ifaceNames map[string]bool
+	typeParams map[string]bool // Type parameters of the generic struct being parsed
+	variants   []*ast.EnumVariant
+	armStack   [][]*ast.MatchArm
+	body       *ast.Block // Top level block of the last file parsed
+	dir        string     // Directory that the file being parsed imports from
+	loader     *moduleLoader
 }
 
 const Debug = false
@@ -39,7 +51,7 @@ func DebugPrintln(more ...interface{}) {
 func (l *listener) NewNodeID(line int) ast.NodeID {
 	l.nodeID++
 
-	newMeta := &ast.Meta{line, nil}
+	newMeta := &ast.Meta{LineNo: line + l.lineOffset}
 	l.prog.Metadata[l.nodeID] = newMeta
 
 	return l.nodeID
@@ -157,6 +169,10 @@ func (l *listener) ExitStructDef(c *parser.StructDefContext) {
 func (l *listener) EnterNamedStructDef(c *parser.NamedStructDefContext) {
 	DebugPrintln("Entering named struct def")
 
+	l.typeParams = make(map[string]bool)
+	for _, param := range structParams(c.GetParams()) {
+		l.typeParams[param] = true
+	}
 	l.blockStack.Push(&ast.Block{})
 }
 
@@ -166,9 +182,27 @@ func (l *listener) ExitNamedStructDef(c *parser.NamedStructDefContext) {
 	ident := fmt.Sprintf("%s", c.GetIdent().GetText())
 	structDef := l.PopStructDef()
 	structDef.Type.Name = ident
+	structDef.Params = structParams(c.GetParams())
+	l.typeParams = nil
+	structDef.NodeID = l.NewNodeID(c.GetStart().GetLine())
+	l.prog.Meta(structDef).Doc = l.docComment(c.GetStart())
 	l.nodeStack.Push(&ast.Assign{&ast.Ident{ident, l.NewNodeID(c.GetStart().GetLine())}, structDef, l.NewNodeID(c.GetStart().GetLine())})
 }
 
+// structParams returns the names of the type parameters of a generic struct
+func structParams(params parser.IArglistContext) []string {
+	if params == nil {
+		return nil
+	}
+
+	names := make([]string, 0)
+	for _, param := range filterCommas(params.GetChildren()) {
+		names = append(names, fmt.Sprintf("%s", param))
+	}
+
+	return names
+}
+
 func (l *listener) PopStructDef() *ast.StructDef {
 	block := l.blockStack.Pop()
 	newStruct := &ast.StructDef{}
@@ -179,6 +213,246 @@ func (l *listener) PopStructDef() *ast.StructDef {
 	return newStruct
 }
 
+func (l *listener) EnterEnumDef(c *parser.EnumDefContext) {
+	DebugPrintln("Entering enum def")
+
+	l.variants = make([]*ast.EnumVariant, 0)
+}
+
+func (l *listener) ExitEnumDef(c *parser.EnumDefContext) {
+	DebugPrintln("Exiting enum def")
+
+	enumDef := &ast.EnumDef{l.variants, types.EnumType{c.GetIdent().GetText()}, l.NewNodeID(c.GetStart().GetLine())}
+	l.prog.Meta(enumDef).Doc = l.docComment(c.GetStart())
+	l.nodeStack.Push(enumDef)
+}
+
+func (l *listener) EnterInterfaceDef(c *parser.InterfaceDefContext) {
+	DebugPrintln("Entering interface def")
+
+	l.blockStack.Push(&ast.Block{})
+}
+
+func (l *listener) ExitInterfaceDef(c *parser.InterfaceDefContext) {
+	DebugPrintln("Exiting interface def")
+
+	// Interface methods are written like struct members with function types
+	methods := l.PopStructDef().Members
+	ifaceDef := &ast.InterfaceDef{methods, types.InterfaceType{c.GetIdent().GetText()}, l.NewNodeID(c.GetStart().GetLine())}
+	l.prog.Meta(ifaceDef).Doc = l.docComment(c.GetStart())
+	l.nodeStack.Push(ifaceDef)
+}
+
+func (l *listener) EnterVariantline(c *parser.VariantlineContext) {
+	DebugPrintln("Entering variant line")
+}
+
+func (l *listener) ExitVariantline(c *parser.VariantlineContext) {
+	DebugPrintln("Exiting variant line")
+
+	variant := &ast.EnumVariant{c.GetIdent().GetText(), []types.Type{}}
+	if c.GetVarianttypes() != nil {
+		typeCount := int(math.Ceil(float64(c.GetVarianttypes().GetChildCount()) / 2.0))
+		for i := 0; i < typeCount; i++ {
+			variant.Types = append([]types.Type{l.typeStack.Pop()}, variant.Types...)
+		}
+	}
+
+	l.variants = append(l.variants, variant)
+}
+
+// declaredNames finds the names of every enum or interface declared in the token stream, so types can
+// refer to them before they're declared
+func declaredNames(stream *antlr.CommonTokenStream, keyword int) map[string]bool {
+	names := make(map[string]bool)
+	tokens := stream.GetAllTokens()
+	for k, tok := range tokens {
+		if tok.GetTokenType() != keyword {
+			continue
+		}
+
+		for _, next := range tokens[k+1:] {
+			if next.GetChannel() != antlr.TokenDefaultChannel {
+				continue
+			}
+			if next.GetTokenType() == parser.DandelionLexIDENT {
+				names[next.GetText()] = true
+			}
+			break
+		}
+	}
+
+	return names
+}
+
+func (l *listener) EnterMatch(c *parser.MatchContext) {
+	DebugPrintln("Entering match")
+
+	l.armStack = append(l.armStack, make([]*ast.MatchArm, 0))
+}
+
+func (l *listener) ExitMatch(c *parser.MatchContext) {
+	DebugPrintln("Exiting match")
+
+	arms := l.armStack[len(l.armStack)-1]
+	l.armStack = l.armStack[:len(l.armStack)-1]
+
+	l.nodeStack.Push(&ast.Match{l.nodeStack.Pop(), arms, l.NewNodeID(c.GetStart().GetLine())})
+}
+
+func (l *listener) EnterMatcharm(c *parser.MatcharmContext) {
+	DebugPrintln("Entering match arm")
+
+	l.blockStack.Push(&ast.Block{})
+}
+
+func (l *listener) ExitMatcharm(c *parser.MatcharmContext) {
+	DebugPrintln("Exiting match arm")
+
+	body := l.blockStack.Pop()
+	if c.Body() == nil {
+		// Arms without braces are a single expression
+		body.Lines = append(body.Lines, l.nodeStack.Pop())
+	}
+
+	var guard ast.Node
+	if c.GetGuard() != nil {
+		guard = l.nodeStack.Pop()
+	}
+
+	arm := &ast.MatchArm{l.nodeStack.Pop(), guard, body}
+	l.armStack[len(l.armStack)-1] = append(l.armStack[len(l.armStack)-1], arm)
+}
+
+// popPatterns pops the patterns in a pattern list off of the node stack, in order
+func (l *listener) popPatterns(patternList antlr.Tree) []ast.Node {
+	if patternList == nil {
+		return []ast.Node{}
+	}
+
+	patterns := make([]ast.Node, len(filterCommas(patternList.GetChildren())))
+	for i := len(patterns) - 1; i >= 0; i-- {
+		patterns[i] = l.nodeStack.Pop()
+	}
+
+	return patterns
+}
+
+func (l *listener) EnterVariantPattern(c *parser.VariantPatternContext) {
+	DebugPrintln("Entering variant pattern")
+}
+
+func (l *listener) ExitVariantPattern(c *parser.VariantPatternContext) {
+	DebugPrintln("Exiting variant pattern")
+
+	patterns := l.popPatterns(c.GetPatterns())
+	l.nodeStack.Push(&ast.VariantPattern{c.GetVariant().GetText(), patterns, l.NewNodeID(c.GetStart().GetLine())})
+}
+
+func (l *listener) EnterTuplePattern(c *parser.TuplePatternContext) {
+	DebugPrintln("Entering tuple pattern")
+}
+
+func (l *listener) ExitTuplePattern(c *parser.TuplePatternContext) {
+	DebugPrintln("Exiting tuple pattern")
+
+	l.nodeStack.Push(&ast.TuplePattern{l.popPatterns(c.GetPatterns()), l.NewNodeID(c.GetStart().GetLine())})
+}
+
+func (l *listener) EnterStructPattern(c *parser.StructPatternContext) {
+	DebugPrintln("Entering struct pattern")
+}
+
+func (l *listener) ExitStructPattern(c *parser.StructPatternContext) {
+	DebugPrintln("Exiting struct pattern")
+
+	fields := make([]string, 0)
+	patterns := make([]ast.Node, 0)
+	if c.GetFields() != nil {
+		fieldCtxs := filterCommas(c.GetFields().GetChildren())
+		fields = make([]string, len(fieldCtxs))
+		patterns = make([]ast.Node, len(fieldCtxs))
+		for i := len(fieldCtxs) - 1; i >= 0; i-- {
+			fields[i] = fieldCtxs[i].(*parser.FieldpatternContext).GetField().GetText()
+			patterns[i] = l.nodeStack.Pop()
+		}
+	}
+
+	l.nodeStack.Push(&ast.StructPattern{c.GetName().GetText(), fields, patterns, l.NewNodeID(c.GetStart().GetLine())})
+}
+
+func (l *listener) EnterTypePattern(c *parser.TypePatternContext) {
+	DebugPrintln("Entering type pattern")
+}
+
+func (l *listener) ExitTypePattern(c *parser.TypePatternContext) {
+	DebugPrintln("Exiting type pattern")
+
+	line := c.GetStart().GetLine()
+	bind := &ast.Ident{c.GetBind().GetText(), l.NewNodeID(line)}
+	l.nodeStack.Push(&ast.TypePattern{bind, l.typeStack.Pop(), l.NewNodeID(line)})
+}
+
+func (l *listener) EnterLiteralPattern(c *parser.LiteralPatternContext) {
+	DebugPrintln("Entering literal pattern")
+}
+
+func (l *listener) ExitLiteralPattern(c *parser.LiteralPatternContext) {
+	DebugPrintln("Exiting literal pattern")
+
+	line := c.GetStart().GetLine()
+	text := c.GetText()
+	var literal ast.Node
+	switch c.GetStart().GetTokenType() {
+	case parser.DandelionLexNUMBER:
+		value, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			panic("Invalid value for int")
+		}
+		literal = &ast.Num{value, l.NewNodeID(line)}
+	case parser.DandelionLexFLOAT:
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			panic("error parsing float: " + err.Error())
+		}
+		literal = &ast.FloatExp{value, l.NewNodeID(line)}
+	case parser.DandelionLexSTRING:
+		pieces, err := splitInterp(text[1 : len(text)-1])
+		if err == nil && (len(pieces) > 1 || len(pieces) == 1 && pieces[0].interp) {
+			err = fmt.Errorf("string patterns can't be interpolated")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Fatal Parse Error: line %d - %s\n", line, err)
+			os.Exit(1)
+		}
+
+		value := ""
+		if len(pieces) == 1 {
+			value = pieces[0].text
+		}
+		literal = &ast.StrExp{value, l.NewNodeID(line)}
+	case parser.DandelionLexBYTE:
+		if text == "'\\n'" {
+			text = "'\n"
+		}
+		literal = &ast.ByteExp{text[1], l.NewNodeID(line)}
+	default:
+		literal = &ast.BoolExp{text == "true", l.NewNodeID(line)}
+	}
+
+	l.nodeStack.Push(literal)
+}
+
+func (l *listener) EnterBindPattern(c *parser.BindPatternContext) {
+	DebugPrintln("Entering bind pattern")
+}
+
+func (l *listener) ExitBindPattern(c *parser.BindPatternContext) {
+	DebugPrintln("Exiting bind pattern")
+
+	l.nodeStack.Push(&ast.Ident{c.GetText(), l.NewNodeID(c.GetStart().GetLine())})
+}
+
 func (l *listener) EnterTypeline(c *parser.TypelineContext) {
 	DebugPrintln("Entering type line")
 }
@@ -214,12 +488,35 @@ func (l *listener) ExitBaseType(c *parser.BaseTypeContext) {
 	case "any":
 		t = types.AnyType{}
 	default:
-		t = types.StructType{text}
+		if l.typeParams[text] {
+			t = types.ParamType{text}
+		} else if l.enumNames[text] {
+			t = types.EnumType{text}
+		} else if l.ifaceNames[text] {
+			t = types.InterfaceType{text}
+		} else {
+			t = types.StructType{Name: text}
+		}
 	}
 
 	l.typeStack.Push(t)
 }
 
+func (l *listener) EnterGenericType(c *parser.GenericTypeContext) {
+	DebugPrintln("Entering generic type")
+}
+
+func (l *listener) ExitGenericType(c *parser.GenericTypeContext) {
+	DebugPrintln("Exiting generic type")
+
+	structType := types.StructType{Name: c.GetName().GetText()}
+	typeCount := int(math.Ceil(float64(c.GetParams().GetChildCount()) / 2.0))
+	for i := 0; i < typeCount; i++ {
+		structType.Params = append([]types.Type{l.typeStack.Pop()}, structType.Params...)
+	}
+	l.typeStack.Push(structType)
+}
+
 func (l *listener) EnterAnyType(c *parser.AnyTypeContext) {
 	DebugPrintln("Entering any type")
 }
@@ -270,6 +567,17 @@ func (l *listener) ExitTypedArr(c *parser.TypedArrContext) {
 	l.typeStack.Push(types.ArrayType{l.typeStack.Pop()})
 }
 
+func (l *listener) EnterTypedMap(c *parser.TypedMapContext) {
+	DebugPrintln("Entering typed map")
+}
+
+func (l *listener) ExitTypedMap(c *parser.TypedMapContext) {
+	DebugPrintln("Exiting typed map")
+	valueType := l.typeStack.Pop()
+	keyType := l.typeStack.Pop()
+	l.typeStack.Push(types.MapType{keyType, valueType})
+}
+
 func (l *listener) EnterStructAccess(c *parser.StructAccessContext) {
 	DebugPrintln("Entering struct access")
 }
@@ -319,12 +627,7 @@ func (l *listener) EnterStart(c *parser.StartContext) {
 }
 
 func (l *listener) ExitStart(c *parser.StartContext) {
-	mainFunc := ast.NewFunDef()
-	mainFunc.Args = []ast.Node{}
-	mainFunc.TypeHint = &types.FuncType{[]types.Type{}, types.IntType{}}
-	mainFunc.Body = l.blockStack.Pop()
-	mainFunc.Body.Lines = append(mainFunc.Body.Lines, &ast.ReturnExp{&ast.Num{0, ast.NoID}, "main", ast.NoID})
-	l.prog.Funcs["main"] = mainFunc
+	l.body = l.blockStack.Pop()
 }
 
 func (l *listener) EnterFunApp(c *parser.FunAppContext) {
@@ -447,14 +750,95 @@ func (l *listener) EnterIf(c *parser.IfContext) {
 func (l *listener) ExitIf(c *parser.IfContext) {
 	DebugPrintln("Exiting if")
 
+	var elseBody *ast.Block
+	if c.ElseBranch() != nil {
+		elseBody = l.blockStack.Pop()
+	}
+
+	elifs := make([]*ast.If, len(c.AllElifBranch()))
+	for i := len(elifs) - 1; i >= 0; i-- {
+		elifs[i] = l.nodeStack.Pop().(*ast.If)
+	}
+
 	ifNode := &ast.If{}
 	ifNode.Cond = l.nodeStack.Pop()
 	ifNode.Body = l.blockStack.Pop()
 	ifNode.NodeID = l.NewNodeID(c.GetStart().GetLine())
 
+	// Chain the elifs together, each one becomes the only line of the previous branch's else block
+	lastIf := ifNode
+	for _, elif := range elifs {
+		lastIf.Else = &ast.Block{[]ast.Node{elif}}
+		lastIf = elif
+	}
+	lastIf.Else = elseBody
+
 	l.nodeStack.Push(ifNode)
 }
 
+func (l *listener) EnterElifBranch(c *parser.ElifBranchContext) {
+	DebugPrintln("Entering elif")
+
+	l.blockStack.Push(&ast.Block{})
+}
+
+func (l *listener) ExitElifBranch(c *parser.ElifBranchContext) {
+	DebugPrintln("Exiting elif")
+
+	elifNode := &ast.If{}
+	elifNode.Cond = l.nodeStack.Pop()
+	elifNode.Body = l.blockStack.Pop()
+	elifNode.NodeID = l.NewNodeID(c.GetStart().GetLine())
+
+	l.nodeStack.Push(elifNode)
+}
+
+func (l *listener) EnterElseBranch(c *parser.ElseBranchContext) {
+	DebugPrintln("Entering else")
+
+	// The else block is left on the stack for ExitIf to pick up
+	l.blockStack.Push(&ast.Block{})
+}
+
+func (l *listener) ExitElseBranch(c *parser.ElseBranchContext) {
+	DebugPrintln("Exiting else")
+}
+
+func (l *listener) EnterTryCatch(c *parser.TryCatchContext) {
+	DebugPrintln("Entering try")
+
+	l.blockStack.Push(&ast.Block{})
+}
+
+func (l *listener) ExitTryCatch(c *parser.TryCatchContext) {
+	DebugPrintln("Exiting try")
+
+	tryNode := &ast.TryCatch{}
+	tryNode.Catch = l.blockStack.Pop()
+	tryNode.Body = l.blockStack.Pop()
+	catch := c.CatchBranch().(*parser.CatchBranchContext)
+	tryNode.Err = &ast.Ident{catch.GetIdent().GetText(), l.NewNodeID(catch.GetStart().GetLine())}
+	tryNode.NodeID = l.NewNodeID(c.GetStart().GetLine())
+
+	l.nodeStack.Push(tryNode)
+}
+
+func (l *listener) EnterCatchBranch(c *parser.CatchBranchContext) {
+	DebugPrintln("Entering catch")
+
+	// The catch block is left on the stack for ExitTryCatch to pick up
+	l.blockStack.Push(&ast.Block{})
+}
+
+func (l *listener) ExitCatchBranch(c *parser.CatchBranchContext) {
+	DebugPrintln("Exiting catch")
+}
+
+func (l *listener) ExitThrow(c *parser.ThrowContext) {
+	DebugPrintln("Exiting throw")
+	l.nodeStack.Push(&ast.Throw{l.nodeStack.Pop(), l.NewNodeID(c.GetStart().GetLine())})
+}
+
 func (l *listener) EnterExtern(c *parser.ExternContext) {
 	DebugPrintln("Entering extern")
 }
@@ -500,11 +884,20 @@ func (l *listener) EnterForIter(c *parser.ForIterContext) {
 func (l *listener) ExitForIter(c *parser.ForIterContext) {
 	DebugPrintln("Exiting for iter")
 
-	itemName := c.GetIname().GetText()
 	iterInit := l.nodeStack.Pop()
 	body := l.blockStack.Pop()
 
-	forIter := &ast.ForIter{&ast.Ident{itemName, l.NewNodeID(c.GetStart().GetLine())}, iterInit, body, l.NewNodeID(c.GetStart().GetLine())}
+	var item ast.Node
+	if c.GetIname() != nil {
+		item = &ast.Ident{c.GetIname().GetText(), l.NewNodeID(c.GetStart().GetLine())}
+	} else {
+		// Key value pairs are destructured into a tuple of the two names
+		keyIdent := &ast.Ident{c.GetKname().GetText(), l.NewNodeID(c.GetStart().GetLine())}
+		valueIdent := &ast.Ident{c.GetVname().GetText(), l.NewNodeID(c.GetStart().GetLine())}
+		item = &ast.TupleLiteral{[]ast.Node{keyIdent, valueIdent}, l.NewNodeID(c.GetStart().GetLine())}
+	}
+
+	forIter := &ast.ForIter{item, iterInit, body, l.NewNodeID(c.GetStart().GetLine())}
 	l.nodeStack.Push(forIter)
 }
 
@@ -545,9 +938,53 @@ func (l *listener) ExitAssign(c *parser.AssignContext) {
 	assignNode.Expr = l.nodeStack.Pop()
 	assignNode.Target = l.nodeStack.Pop()
 	assignNode.NodeID = l.NewNodeID(c.GetStart().GetLine())
+
+	switch assignNode.Expr.(type) {
+	case *ast.FunDef, *ast.StructDef:
+		l.prog.Meta(assignNode.Expr).Doc = l.docComment(c.GetStart())
+	}
+
 	l.nodeStack.Push(assignNode)
 }
 
+// docComment returns the text of the comments directly before the token, without comment markers
+func (l *listener) docComment(start antlr.Token) string {
+	if l.tokens == nil {
+		return ""
+	}
+
+	lines := make([]string, 0)
+	newlines := 0
+	for _, comment := range l.tokens.GetHiddenTokensToLeft(start.GetTokenIndex(), antlr.TokenHiddenChannel) {
+		// A blank line separates comments from the definition below them
+		if comment.GetTokenType() == parser.DandelionLexNEWLINE {
+			newlines++
+			if newlines > 1 {
+				lines = lines[:0]
+			}
+			continue
+		}
+		newlines = 0
+
+		text := comment.GetText()
+		switch {
+		case strings.HasPrefix(text, "/*"):
+			text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+			for _, line := range strings.Split(text, "\n") {
+				lines = append(lines, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*")))
+			}
+			continue
+		case strings.HasPrefix(text, "//"):
+			text = strings.TrimPrefix(text, "//")
+		default:
+			text = strings.TrimPrefix(text, "#")
+		}
+		lines = append(lines, strings.TrimSpace(text))
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
 func (l *listener) EnterCompExp(c *parser.CompExpContext) {
 	DebugPrintln("Enter comp exp")
 }
@@ -564,6 +1001,58 @@ func (l *listener) ExitCompExp(c *parser.CompExpContext) {
 	l.nodeStack.Push(compNode)
 }
 
+func (l *listener) EnterAndOr(c *parser.AndOrContext) {
+	DebugPrintln("Enter and/or exp")
+}
+
+func (l *listener) ExitAndOr(c *parser.AndOrContext) {
+	DebugPrintln("Exit and/or exp")
+
+	andOrNode := &ast.AndOr{}
+	andOrNode.Op = c.GetOp().GetText()
+	andOrNode.Right = l.nodeStack.Pop()
+	andOrNode.Left = l.nodeStack.Pop()
+	andOrNode.NodeID = l.NewNodeID(c.GetStart().GetLine())
+
+	l.nodeStack.Push(andOrNode)
+}
+
+func (l *listener) EnterNotExp(c *parser.NotExpContext) {
+	DebugPrintln("Enter not exp")
+}
+
+func (l *listener) ExitNotExp(c *parser.NotExpContext) {
+	DebugPrintln("Exit not exp")
+
+	l.nodeStack.Push(&ast.Not{l.nodeStack.Pop(), l.NewNodeID(c.GetStart().GetLine())})
+}
+
+func (l *listener) EnterBitExp(c *parser.BitExpContext) {
+	DebugPrintln("Enter bit exp")
+}
+
+func (l *listener) ExitBitExp(c *parser.BitExpContext) {
+	DebugPrintln("Exit bit exp")
+
+	bitNode := &ast.BitOp{}
+	bitNode.Op = c.GetOp().GetText()
+	bitNode.Right = l.nodeStack.Pop()
+	bitNode.Left = l.nodeStack.Pop()
+	bitNode.NodeID = l.NewNodeID(c.GetStart().GetLine())
+
+	l.nodeStack.Push(bitNode)
+}
+
+func (l *listener) EnterComplementExp(c *parser.ComplementExpContext) {
+	DebugPrintln("Enter complement exp")
+}
+
+func (l *listener) ExitComplementExp(c *parser.ComplementExpContext) {
+	DebugPrintln("Exit complement exp")
+
+	l.nodeStack.Push(&ast.Complement{l.nodeStack.Pop(), l.NewNodeID(c.GetStart().GetLine())})
+}
+
 func (l *listener) EnterBoolExp(c *parser.BoolExpContext) {
 	DebugPrintln("Entering bool literal")
 }
@@ -654,6 +1143,50 @@ func (l *listener) ExitArray(c *parser.ArrayContext) {
 	l.nodeStack.Push(newArr)
 }
 
+func (l *listener) EnterMapLit(c *parser.MapLitContext) {
+	DebugPrintln("Entering map literal")
+}
+
+func (l *listener) ExitMapLit(c *parser.MapLitContext) {
+	DebugPrintln("Exiting map literal")
+
+	newMap := &ast.MapLiteral{}
+	entryCount := 0
+	if c.GetEntries() != nil {
+		entryCount = len(filterCommas(c.GetEntries().GetChildren()))
+	}
+
+	for i := 0; i < entryCount; i++ {
+		newMap.Values = append([]ast.Node{l.nodeStack.Pop()}, newMap.Values...)
+		newMap.Keys = append([]ast.Node{l.nodeStack.Pop()}, newMap.Keys...)
+	}
+
+	if entryCount == 0 {
+		l.emptyArrNo++
+		newMap.EmptyNo = l.emptyArrNo
+	} else {
+		newMap.EmptyNo = -1
+	}
+
+	newMap.NodeID = l.NewNodeID(c.GetStart().GetLine())
+	l.nodeStack.Push(newMap)
+}
+
+func (l *listener) EnterInExp(c *parser.InExpContext) {
+	DebugPrintln("Enter in exp")
+}
+
+func (l *listener) ExitInExp(c *parser.InExpContext) {
+	DebugPrintln("Exit in exp")
+
+	inNode := &ast.InExp{}
+	inNode.Container = l.nodeStack.Pop()
+	inNode.Item = l.nodeStack.Pop()
+	inNode.NodeID = l.NewNodeID(c.GetStart().GetLine())
+
+	l.nodeStack.Push(inNode)
+}
+
 func (l *listener) EnterTuple(c *parser.TupleContext) {
 	DebugPrintln("Entering tuple")
 }
@@ -687,6 +1220,26 @@ func (l *listener) ExitSliceExp(c *parser.SliceExpContext) {
 	l.nodeStack.Push(sliceNode)
 }
 
+func (l *listener) EnterRangeSliceExp(c *parser.RangeSliceExpContext) {
+	DebugPrintln("Entering range slice exp")
+}
+
+func (l *listener) ExitRangeSliceExp(c *parser.RangeSliceExpContext) {
+	DebugPrintln("Exiting range slice exp")
+
+	sliceNode := &ast.RangeSlice{}
+	if c.GetHigh() != nil {
+		sliceNode.High = l.nodeStack.Pop()
+	}
+	if c.GetLow() != nil {
+		sliceNode.Low = l.nodeStack.Pop()
+	}
+	sliceNode.Arr = l.nodeStack.Pop()
+
+	sliceNode.NodeID = l.NewNodeID(c.GetStart().GetLine())
+	l.nodeStack.Push(sliceNode)
+}
+
 func (l *listener) EnterTupleAccess(c *parser.TupleAccessContext) {
 	DebugPrintln("Entering tuple access")
 }
@@ -717,19 +1270,81 @@ func (l *listener) EnterCommandExp(c *parser.CommandExpContext) {
 func (l *listener) ExitCommandExp(c *parser.CommandExpContext) {
 	DebugPrintln("Exiting command exp")
 
-	command := &ast.CommandExp{}
-	splitCommand := strings.Split(c.GetText()[1:len(c.GetText())-1], " ")
-	command.Command = splitCommand[0]
+	line := c.GetStart().GetLine()
+	words, err := splitCommand(c.GetText()[1 : len(c.GetText())-1])
+	if err == nil && len(words) == 0 {
+		err = errors.New("empty command")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal Parse Error: line %d - %s\n", line, err)
+		os.Exit(1)
+	}
 
-	// TODO: Support more advanced command syntax
-	for i := 1; i < len(splitCommand); i++ {
-		command.Args = append(command.Args, splitCommand[i])
+	command := &ast.CommandExp{}
+	for _, word := range words {
+		command.Args = append(command.Args, l.commandWord(word, line))
 	}
 
-	command.NodeID = l.NewNodeID(c.GetStart().GetLine())
+	command.NodeID = l.NewNodeID(line)
 	l.nodeStack.Push(command)
 }
 
+// commandWord joins the literal and interpolated pieces of a command word into one string expression
+func (l *listener) commandWord(pieces []textPiece, line int) ast.Node {
+	if len(pieces) == 0 {
+		// Empty quotes still make an argument
+		return &ast.StrExp{"", l.NewNodeID(line)}
+	}
+
+	return l.interpNode(pieces, line)
+}
+
+// interpNode builds a plain string from literal text, or an interpolated string if any piece is an expression
+func (l *listener) interpNode(pieces []textPiece, line int) ast.Node {
+	if len(pieces) == 1 && !pieces[0].interp {
+		return &ast.StrExp{pieces[0].text, l.NewNodeID(line)}
+	}
+
+	interp := &ast.InterpStr{}
+	for _, piece := range pieces {
+		if piece.interp {
+			interp.Parts = append(interp.Parts, l.parseEmbedded(piece.text, line))
+		} else {
+			interp.Parts = append(interp.Parts, &ast.StrExp{piece.text, l.NewNodeID(line)})
+		}
+	}
+	interp.NodeID = l.NewNodeID(line)
+
+	return interp
+}
+
+// parseEmbedded parses an expression embedded inside another token, like an interpolated value
+func (l *listener) parseEmbedded(text string, line int) ast.Node {
+	is := antlr.NewInputStream(text)
+	lexer := parser.NewDandelionLex(is)
+
+	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+	p := parser.NewDandelion(stream)
+
+	errorStrat := &ErrorStrategy{}
+	p.SetErrorHandler(errorStrat)
+	p.RemoveErrorListeners()
+	p.AddErrorListener(&ErrorListener{})
+
+	// Nodes from the embedded expression are reported on the line of the token containing it
+	prevOffset, prevTokens := l.lineOffset, l.tokens
+	l.lineOffset, l.tokens = line-1, stream
+	antlr.ParseTreeWalkerDefault.Walk(l, p.Expr())
+	l.lineOffset, l.tokens = prevOffset, prevTokens
+
+	if errorStrat.parseErrors > 0 || stream.LA(1) != antlr.TokenEOF {
+		fmt.Fprintf(os.Stderr, "Fatal Parse Error: line %d - invalid interpolated expression '%s'\n", line, text)
+		os.Exit(1)
+	}
+
+	return l.nodeStack.Pop()
+}
+
 func (l *listener) EnterPipeExp(c *parser.PipeExpContext) {
 	DebugPrintln("Entering pipe exp")
 }
@@ -781,9 +1396,20 @@ func (l *listener) EnterStrExp(c *parser.StrExpContext) {
 
 func (l *listener) ExitStrExp(c *parser.StrExpContext) {
 	DebugPrintln("Exiting string", c.GetText())
+	line := c.GetStart().GetLine()
 	text := c.GetText()[1 : len(c.GetText())-1]
-	text = strings.Replace(text, "\\n", "\n", -1)
-	l.nodeStack.Push(&ast.StrExp{text, l.NewNodeID(c.GetStart().GetLine())})
+
+	pieces, err := splitInterp(text)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal Parse Error: line %d - %s\n", line, err)
+		os.Exit(1)
+	}
+	if len(pieces) == 0 {
+		l.nodeStack.Push(&ast.StrExp{"", l.NewNodeID(line)})
+		return
+	}
+
+	l.nodeStack.Push(l.interpNode(pieces, line))
 }
 
 func filterCommas(elems []antlr.Tree) []antlr.Tree {
@@ -798,12 +1424,60 @@ func filterCommas(elems []antlr.Tree) []antlr.Tree {
 	return notCommas
 }
 
+// ParseProgram parses a program on its own, without the prelude. Its imports are relative to the working directory.
 func ParseProgram(text string) *ast.Program {
-	semiText := insertSemis(text)
-	is := antlr.NewInputStream(semiText)
+	return parseProgram(text, "", false)
+}
+
+// ParseSource parses the program in the file at path, along with the prelude and every module it imports.
+// The modules are merged into the program's main function, before its own code. Path is empty for
+// programs that aren't in a file.
+func ParseSource(text string, path string) *ast.Program {
+	return parseProgram(text, path, true)
+}
+
+func parseProgram(text string, path string, withPrelude bool) *ast.Program {
+	l := &listener{}
+	l.typeStack = &TypeStack{}
+	l.prog = ast.NewProgram()
+	l.enumNames = make(map[string]bool)
+	l.ifaceNames = make(map[string]bool)
+	l.loader = newModuleLoader()
+
+	dir := "."
+	if path != "" {
+		dir = filepath.Dir(path)
+		l.loader.loading = append(l.loader.loading, absPath(path))
+	}
+	if withPrelude {
+		l.parsePrelude()
+	}
+	body := l.parse(text, dir, path)
+
+	lines := make([]ast.Node, 0)
+	for _, module := range l.loader.order {
+		lines = append(lines, module)
+	}
+
+	mainFunc := ast.NewFunDef()
+	mainFunc.Args = []ast.Node{}
+	mainFunc.TypeHint = &types.FuncType{[]types.Type{}, types.IntType{}}
+	mainFunc.Body = &ast.Block{append(lines, body.Lines...)}
+	mainFunc.Body.Lines = append(mainFunc.Body.Lines, &ast.ReturnExp{&ast.Num{0, ast.NoID}, "main", ast.NoID})
+	l.prog.Funcs["main"] = mainFunc
+
+	l.prog.CurrNodeID = l.nodeID + 1
+
+	return l.prog
+}
+
+// parse parses the source of one file and returns its top level block. Every file shares the listener,
+// so node IDs are unique across the whole program.
+func (l *listener) parse(text string, dir string, path string) *ast.Block {
+	is := antlr.NewInputStream(text)
 	lexer := parser.NewDandelionLex(is)
 
-	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
+	stream := antlr.NewCommonTokenStream(newSemiInserter(lexer), antlr.TokenDefaultChannel)
 	p := parser.NewDandelion(stream)
 
 	errorStrat := &ErrorStrategy{}
@@ -811,16 +1485,29 @@ func ParseProgram(text string) *ast.Program {
 	p.RemoveErrorListeners()
 	p.AddErrorListener(&ErrorListener{})
 
-	l := &listener{}
-	l.typeStack = &TypeStack{}
-	l.prog = ast.NewProgram()
-	antlr.ParseTreeWalkerDefault.Walk(l, p.Start())
+	tree := p.Start()
+
+	// Type names are shared by every module in the program
+	for name := range declaredNames(stream, parser.DandelionLexENUM) {
+		l.enumNames[name] = true
+	}
+	for name := range declaredNames(stream, parser.DandelionLexINTERFACE) {
+		l.ifaceNames[name] = true
+	}
+
+	prevTokens, prevDir := l.tokens, l.dir
+	l.tokens, l.dir = stream, dir
+	antlr.ParseTreeWalkerDefault.Walk(l, tree)
+	l.tokens, l.dir = prevTokens, prevDir
+
 	if errorStrat.parseErrors > 0 {
-		fmt.Fprintf(os.Stderr, "%d parse errors encountered", errorStrat.parseErrors)
+		if path != "" {
+			fmt.Fprintf(os.Stderr, "%d parse errors encountered in %s", errorStrat.parseErrors, path)
+		} else {
+			fmt.Fprintf(os.Stderr, "%d parse errors encountered", errorStrat.parseErrors)
+		}
 		os.Exit(1)
 	}
 
-	l.prog.CurrNodeID = l.nodeID + 1
-
-	return l.prog
+	return l.body
 }
@@ -1,8 +1,16 @@
 package parser
 
 import (
+	parser "dandelion/aparser"
+	"dandelion/ast"
+	"dandelion/types"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
 )
 
 func TestTypedFunc(t *testing.T) {
@@ -36,3 +44,214 @@ func TestParsePipeline(t *testing.T) {
 
 	fmt.Println(ParseProgram(src))
 }
+
+func TestSplitCommand(t *testing.T) {
+	words, err := splitCommand(`grep -n "two words" 'a {b}' pre{name}post {m["}"]} esc\ aped ""`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]textPiece{
+		{{"grep", false}},
+		{{"-n", false}},
+		{{"two words", false}},
+		{{"a {b}", false}},
+		{{"pre", false}, {"name", true}, {"post", false}},
+		{{`m["}"]`, true}},
+		{{"esc aped", false}},
+		nil,
+	}
+	if !reflect.DeepEqual(words, expected) {
+		t.Fatalf("unexpected command split: %v", words)
+	}
+
+	for _, bad := range []string{`echo "open`, `echo {x`, `echo {}`, `echo \`} {
+		_, err := splitCommand(bad)
+		if err == nil {
+			t.Errorf("expected error splitting %s", bad)
+		}
+	}
+}
+
+func TestSplitInterp(t *testing.T) {
+	pieces, err := splitInterp(`found {n} matches\n in {files[0]}\{\}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []textPiece{
+		{"found ", false},
+		{"n", true},
+		{" matches\n in ", false},
+		{"files[0]", true},
+		{"{}", false},
+	}
+	if !reflect.DeepEqual(pieces, expected) {
+		t.Fatalf("unexpected string split: %v", pieces)
+	}
+
+	for _, bad := range []string{`open {x`, `empty {}`, `trailing \`} {
+		_, err := splitInterp(bad)
+		if err == nil {
+			t.Errorf("expected error splitting %s", bad)
+		}
+	}
+}
+
+// semiTokens lexes a program and returns the tokens the parser sees, separated by spaces
+func semiTokens(text string) string {
+	semis := newSemiInserter(parser.NewDandelionLex(antlr.NewInputStream(text)))
+
+	toks := make([]string, 0)
+	for tok := semis.NextToken(); tok.GetTokenType() != antlr.TokenEOF; tok = semis.NextToken() {
+		if tok.GetChannel() == antlr.TokenDefaultChannel {
+			toks = append(toks, tok.GetText())
+		}
+	}
+
+	return strings.Join(toks, " ")
+}
+
+func TestInsertSemisComments(t *testing.T) {
+	src := `x = foo() // call (it)
+y = "# not a comment" # trailing
+/* block
+   print(x) */
+z = [1, 2] /* inline */
+if x {
+	x
+} // done
+else {
+	y
+}`
+
+	expected := `x = foo ( ) ; y = "# not a comment" ; z = [ 1 , 2 ] ; if x { x ; } else { y ; } ;`
+	if result := semiTokens(src); result != expected {
+		t.Fatalf("unexpected semicolon insertion:\n%s", result)
+	}
+}
+
+func TestInsertSemisContinued(t *testing.T) {
+	src := `total = add(1,
+	2)
+nums = [
+	1,
+	2
+]
+m = {
+	"a": 1,
+	"b": f{
+		e
+	}
+}
+x = 1 +
+	2
+nums
+	-> f{ e }
+
+	-> p
+ok = x == 3 &&
+	true`
+
+	expected := `total = add ( 1 , 2 ) ; nums = [ 1 , 2 ] ; m = { "a" : 1 , "b" : f { e ; } } ; x = 1 + 2 ; ` +
+		`nums -> f { e ; } -> p ; ok = x == 3 && true ;`
+	if result := semiTokens(src); result != expected {
+		t.Fatalf("unexpected semicolon insertion:\n%s", result)
+	}
+}
+
+func TestInsertSemisLeadingMinus(t *testing.T) {
+	src := `x = 1
+-x
+y = f() {
+	x
+	-1
+}`
+
+	expected := `x = 1 ; - x ; y = f ( ) { x ; -1 ; } ;`
+	if result := semiTokens(src); result != expected {
+		t.Fatalf("unexpected semicolon insertion:\n%s", result)
+	}
+}
+
+func TestInsertSemisMatch(t *testing.T) {
+	src := `match p {
+	Point{x: 0, y: y} if y > 0 => y
+	(a, Point{
+		x: 1
+	}) => a
+	_ => 0
+}`
+
+	expected := `match p { Point { x : 0 , y : y } if y > 0 => y ; ( a , Point { x : 1 } ) => a ; _ => 0 ; } ;`
+	if result := semiTokens(src); result != expected {
+		t.Fatalf("unexpected semicolon insertion:\n%s", result)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	src := `
+// Adds two numbers.
+// Returns the sum.
+add = f(a, b) {
+	a + b // not a doc comment
+}
+
+/* A point
+ * in the plane */
+struct Point {
+	x: int
+	y: int
+}
+`
+
+	prog := ParseProgram(src)
+	docs := make([]string, 0)
+	for _, meta := range prog.Metadata {
+		if meta.Doc != "" {
+			docs = append(docs, meta.Doc)
+		}
+	}
+	sort.Strings(docs)
+
+	expected := []string{"A point\nin the plane", "Adds two numbers.\nReturns the sum."}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Fatalf("unexpected doc comments: %q", docs)
+	}
+}
+
+func TestEmptyBraces(t *testing.T) {
+	src := `
+{}
+m = {:}
+n = {"a": 1}
+`
+
+	lines := ParseProgram(src).Funcs["main"].Body.Lines
+	if _, isBlock := lines[0].(*ast.BlockExp); !isBlock {
+		t.Errorf("expected {} to be a block, got %s", lines[0])
+	}
+	for k, entries := range []int{0, 1} {
+		mapLit, isMap := lines[k+1].(*ast.Assign).Expr.(*ast.MapLiteral)
+		if !isMap || len(mapLit.Keys) != entries {
+			t.Errorf("expected a map literal with %d entries, got %s", entries, lines[k+1])
+		}
+	}
+}
+
+func TestMapName(t *testing.T) {
+	src := `
+map = f(a, fn) { a -> fn }
+counts = {:}: map[string]int
+`
+
+	prog := ParseProgram(src)
+	lines := prog.Funcs["main"].Body.Lines
+	if target := lines[0].(*ast.Assign).Target; target.(*ast.Ident).Value != "map" {
+		t.Errorf("expected an assignment to map, got %s", lines[0])
+	}
+	hint := prog.Meta(lines[1].(*ast.Assign).Expr).Hint
+	if hint == nil || !types.Equals(hint, types.MapType{types.StringType{}, types.IntType{}}) {
+		t.Errorf("expected a map[string]int hint, got %s", lines[1])
+	}
+}
@@ -1,44 +1,159 @@
 package parser
 
 import (
-	"bufio"
+	parser "dandelion/aparser"
 	"strings"
-	"unicode"
+
+	"github.com/antlr/antlr4/runtime/Go/antlr"
 )
 
-var insertTokens map[string]struct{}
+// semiInserter sits between the lexer and the parser and inserts the semicolons that end statements.
+// A newline ends the statement before it if the last token on the line can end one, unless the newline
+// is inside parentheses, brackets or a map literal, or the next line continues with a token like '->'.
+type semiInserter struct {
+	antlr.Lexer
 
-func init() {
-	insertTokens = make(map[string]struct{})
-	endInsertSet := "qwertyuiopasdfghjklzxcvbnmQWERTYUIOPASDFGHJKLZXCVBNM_1234567890)]}'\""
-	for _, c := range endInsertSet {
-		insertTokens[string(c)] = struct{}{}
-	}
+	pending []antlr.Token
+	last    antlr.Token
+	prev    antlr.Token // The token before last
+	// Whether newlines end statements inside each open bracket. They do only inside blocks.
+	brackets []bool
 }
 
-func insertSemis(progText string) string {
-	builder := strings.Builder{}
+func newSemiInserter(lexer antlr.Lexer) *semiInserter {
+	return &semiInserter{Lexer: lexer}
+}
+
+// Tokens that can end a statement
+var endTokens = tokenSet(parser.DandelionLexIDENT, parser.DandelionLexNUMBER, parser.DandelionLexFLOAT,
+	parser.DandelionLexSTRING, parser.DandelionLexBYTE, parser.DandelionLexCOMMAND, parser.DandelionLexTRUE,
+	parser.DandelionLexFALSE, parser.DandelionLexNULL, parser.DandelionLexBREAK, parser.DandelionLexCONTINUE,
+	parser.DandelionLexANY, parser.DandelionLexINTTYPE, parser.DandelionLexFLOATTYPE, parser.DandelionLexBYTETYPE,
+	parser.DandelionLexRPAREN, parser.DandelionLexRBRACKET, parser.DandelionLexRBRACE)
+
+// Tokens that continue the statement from the previous line when they start a line. Other operators only
+// continue a statement at the end of a line, since a line can start with an expression like '-x'.
+var continueTokens = tokenSet(parser.DandelionLexPIPE, parser.DandelionLexUNROLL, parser.DandelionLexACCESS,
+	parser.DandelionLexELSE, parser.DandelionLexELIF, parser.DandelionLexCATCH)
 
-	scanner := bufio.NewScanner(strings.NewReader(progText))
-	for scanner.Scan() {
-		builder.WriteString(insertLine(scanner.Text()) + "\n")
+// Tokens after which a '{' opens a block rather than a map literal, along with the end tokens
+var blockTokens = tokenSet(parser.DandelionLexSEMICOLON, parser.DandelionLexLBRACE, parser.DandelionLexELSE,
+	parser.DandelionLexFSTART, parser.DandelionLexSTRUCT, parser.DandelionLexARROW, parser.DandelionLexTRY)
+
+// Tokens that can come directly before a struct pattern like 'Point{x: 0}' in a match arm
+var patternStartTokens = tokenSet(parser.DandelionLexSEMICOLON, parser.DandelionLexLBRACE, parser.DandelionLexLPAREN,
+	parser.DandelionLexCOMMA, parser.DandelionLexHINT)
+
+func tokenSet(tokenTypes ...int) map[int]bool {
+	set := make(map[int]bool)
+	for _, tokenType := range tokenTypes {
+		set[tokenType] = true
 	}
 
-	return builder.String()
+	return set
 }
 
-func insertLine(line string) string {
-	for i := len(line) - 1; i >= 0; i-- {
-		if unicode.IsSpace(rune(line[i])) {
-			continue
+func (s *semiInserter) NextToken() antlr.Token {
+	tok := s.nextToken()
+	if tok.GetChannel() == antlr.TokenDefaultChannel {
+		s.track(tok)
+	}
+
+	return tok
+}
+
+func (s *semiInserter) nextToken() antlr.Token {
+	if len(s.pending) > 0 {
+		tok := s.pending[0]
+		s.pending = s.pending[1:]
+		return tok
+	}
+
+	tok := s.Lexer.NextToken()
+	switch {
+	case tok.GetTokenType() == antlr.TokenEOF:
+		if s.endsStatement() {
+			s.pending = append(s.pending, tok)
+			return s.semicolon()
 		}
+	case tok.GetTokenType() == parser.DandelionLexRBRACE:
+		// Like Go, the last statement in a block doesn't need a semicolon before the closing brace
+		if s.endsStatement() {
+			s.pending = append(s.pending, tok)
+			return s.semicolon()
+		}
+	case isNewline(tok):
+		if s.endsStatement() {
+			// Look ahead past blank lines and comments to see if the statement continues
+			s.pending = append(s.pending, tok)
+			next := s.Lexer.NextToken()
+			s.pending = append(s.pending, next)
+			for next.GetChannel() != antlr.TokenDefaultChannel && next.GetTokenType() != antlr.TokenEOF {
+				next = s.Lexer.NextToken()
+				s.pending = append(s.pending, next)
+			}
+
+			if !continueTokens[next.GetTokenType()] {
+				return s.semicolon()
+			}
+			return s.nextToken()
+		}
+	}
 
-		_, ok := insertTokens[string(line[i])]
-		if ok {
-			return line + ";"
+	return tok
+}
+
+// track updates the bracket nesting and last token with a token passed to the parser
+func (s *semiInserter) track(tok antlr.Token) {
+	switch tok.GetTokenType() {
+	case parser.DandelionLexLPAREN, parser.DandelionLexLBRACKET:
+		s.brackets = append(s.brackets, false)
+	case parser.DandelionLexLBRACE:
+		isBlock := s.last == nil || endTokens[s.last.GetTokenType()] || blockTokens[s.last.GetTokenType()]
+		s.brackets = append(s.brackets, isBlock && !s.structPattern())
+	case parser.DandelionLexRPAREN, parser.DandelionLexRBRACKET, parser.DandelionLexRBRACE:
+		if len(s.brackets) > 0 {
+			s.brackets = s.brackets[:len(s.brackets)-1]
 		}
-		break
 	}
 
-	return line
-}
\ No newline at end of file
+	s.prev = s.last
+	s.last = tok
+}
+
+// structPattern returns true if a '{' at the current position starts the fields of a struct pattern.
+// A name directly before a block is always part of a larger expression, like 'if x {'.
+func (s *semiInserter) structPattern() bool {
+	if s.prev == nil || s.last.GetTokenType() != parser.DandelionLexIDENT {
+		return false
+	}
+
+	return patternStartTokens[s.prev.GetTokenType()]
+}
+
+// endsStatement returns true if a newline at the current position ends a statement
+func (s *semiInserter) endsStatement() bool {
+	if s.last == nil || !endTokens[s.last.GetTokenType()] {
+		return false
+	}
+
+	return len(s.brackets) == 0 || s.brackets[len(s.brackets)-1]
+}
+
+// semicolon creates a semicolon token directly after the last token
+func (s *semiInserter) semicolon() antlr.Token {
+	return s.GetTokenFactory().Create(s.last.GetSource(), parser.DandelionLexSEMICOLON, ";", antlr.TokenDefaultChannel,
+		-1, -1, s.last.GetLine(), s.last.GetColumn()+len(s.last.GetText()))
+}
+
+// isNewline returns true for newline tokens, and block comments that span multiple lines
+func isNewline(tok antlr.Token) bool {
+	switch tok.GetTokenType() {
+	case parser.DandelionLexNEWLINE:
+		return true
+	case parser.DandelionLexBLOCK_COMMENT:
+		return strings.Contains(tok.GetText(), "\n")
+	}
+
+	return false
+}
@@ -0,0 +1,31 @@
+// Package prelude holds the Dandelion source of the modules that every program can use without
+// importing them. Each file is a module named after it, so the functions in io.dan are used as io.open,
+// io.lines and so on. Only the functions that a program uses are compiled into it.
+package prelude
+
+import "embed"
+
+// Source is one file of the prelude
+type Source struct {
+	Name string
+	Text string
+}
+
+//go:embed *.dan
+var files embed.FS
+
+// Sources are the files of the prelude, in the order they run
+var Sources = loadSources("io.dan", "strings.dan", "math.dan", "collections.dan")
+
+func loadSources(names ...string) []Source {
+	sources := make([]Source, len(names))
+	for i, name := range names {
+		text, err := files.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		sources[i] = Source{name, string(text)}
+	}
+
+	return sources
+}
@@ -90,6 +90,26 @@ func (f *UnboundFinder) WalkNode(astNode ast.Node) ast.Node {
 			break
 		}
 		f.Defs[targetIdent.Value] = true
+	case *ast.ForIter:
+		// Loop items are bound by the loop itself
+		items := []ast.Node{node.Item}
+		pair, isPair := node.Item.(*ast.TupleLiteral)
+		if isPair {
+			items = pair.Exprs
+		}
+		for _, item := range items {
+			f.Defs[item.(*ast.Ident).Value] = true
+		}
+	case *ast.TryCatch:
+		// The error name is bound by the catch block
+		f.Defs[node.Err.Value] = true
+	case *ast.Match:
+		// Pattern names are bound by the arm they're in
+		for _, arm := range node.Arms {
+			for _, bind := range ast.PatternBinds(arm.Pattern) {
+				f.Defs[bind.Value] = true
+			}
+		}
 	case *ast.Ident:
 		_, ok := f.Defs[node.Value]
 		if !ok {
@@ -163,6 +183,9 @@ func (c *ClosureExtractor) WalkNode(astNode ast.Node) ast.Node {
 		closure.Target = ident
 		closure.ArgTup = &ast.Ident{tupName, ast.NoID}
 		closure.NewFunc = node.Target
+		for _, name := range unboundNames {
+			closure.Unbound = append(closure.Unbound, name.(*ast.Ident).Value)
+		}
 
 		retLines.Nodes = append(retLines.Nodes, &ast.Assign{node.Target, closure, ast.NoID})
 
@@ -195,3 +218,8 @@ func CloArgToTupName(argName string) string {
 	baseClo := strings.TrimSuffix(argName, CloArgSuffix)
 	return baseClo + CloTupSuffix
 }
+
+// CloTupToFunc returns the name of the function that a closure's tuple of captured values belongs to
+func CloTupToFunc(tupName string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(tupName, "clo."), CloTupSuffix)
+}
@@ -0,0 +1,74 @@
+package transform
+
+import "dandelion/ast"
+
+// DiscardFinder marks the ifs and yields whose values are never used
+type DiscardFinder struct {
+	prog      *ast.Program
+	discarded map[ast.Node]bool
+}
+
+// MarkDiscarded marks the ifs and yields that are only used as statements. The branches of those ifs don't
+// have to produce the same type, and those yields don't need anything to be sent to the coroutine.
+// A line's value is discarded unless it's the last line of a block whose value is used.
+func MarkDiscarded(prog *ast.Program) {
+	for _, fun := range prog.Funcs {
+		ast.WalkAst(fun, &DiscardFinder{prog, make(map[ast.Node]bool)})
+	}
+}
+
+func (d *DiscardFinder) WalkNode(astNode ast.Node) ast.Node {
+	switch node := astNode.(type) {
+	case *ast.FunDef:
+		// The last line is only returned when the function doesn't return or yield anywhere else
+		returned := false
+		for _, expr := range node.TermExprs() {
+			_, isYield := expr.(*ast.YieldExp)
+			if !isYield && len(node.Body.Lines) > 0 && expr == node.Body.Lines[len(node.Body.Lines)-1] {
+				returned = true
+			}
+		}
+		d.markLines(node.Body, returned)
+	case *ast.While:
+		d.markLines(node.Body, false)
+	case *ast.For:
+		d.markLines(node.Body, false)
+	case *ast.ForIter:
+		d.markLines(node.Body, false)
+	case *ast.TryCatch:
+		d.markLines(node.Body, false)
+		d.markLines(node.Catch, false)
+	case *ast.BlockExp:
+		d.markLines(node.Block, !d.discarded[node])
+	case *ast.If:
+		for _, branch := range node.Branches() {
+			d.markLines(branch, !d.discarded[node])
+		}
+	case *ast.Match:
+		for _, arm := range node.Arms {
+			d.markLines(arm.Body, !d.discarded[node])
+		}
+	}
+
+	return nil
+}
+
+func (d *DiscardFinder) WalkBlock(block *ast.Block) *ast.Block {
+	return nil
+}
+
+func (d *DiscardFinder) markLines(block *ast.Block, used bool) {
+	for k, line := range block.Lines {
+		if used && k == len(block.Lines)-1 {
+			continue
+		}
+		d.discarded[line] = true
+
+		_, isIf := line.(*ast.If)
+		_, isYield := line.(*ast.YieldExp)
+		meta := d.prog.Meta(line)
+		if (isIf || isYield) && meta != nil {
+			meta.Discarded = true
+		}
+	}
+}
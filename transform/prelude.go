@@ -0,0 +1,107 @@
+package transform
+
+import (
+	"dandelion/ast"
+	"dandelion/parser"
+)
+
+// identFinder collects the names of every identifier it walks over
+type identFinder struct {
+	names map[string]bool
+}
+
+// TrimPrelude removes the definitions in the prelude's modules that the program doesn't use, so they aren't compiled.
+// It runs after renaming, which turns a use like io.open into the new name of its definition.
+func TrimPrelude(prog *ast.Program) {
+	mainFunc := prog.Funcs["main"]
+
+	preludeLines := make([]ast.Node, 0)
+	preludeLoc := -1
+	lines := make([]ast.Node, 0)
+	for _, line := range mainFunc.Body.Lines {
+		module, isModule := line.(*ast.Module)
+		if isModule && parser.IsPrelude(module.Path) {
+			preludeLines = append(preludeLines, module.Body.Lines...)
+			if preludeLoc < 0 {
+				preludeLoc = len(lines)
+			}
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	if preludeLoc < 0 {
+		return
+	}
+
+	defs := make(map[string]ast.Node)
+	for _, line := range preludeLines {
+		assign, isAssign := line.(*ast.Assign)
+		if !isAssign {
+			continue
+		}
+		target, isIdent := assign.Target.(*ast.Ident)
+		if isIdent {
+			defs[target.Value] = assign
+		}
+	}
+
+	// Start from the names the program uses, then add the names that each used definition uses
+	used := findIdents(&ast.Block{lines})
+	unchecked := make([]string, 0)
+	for name := range used {
+		unchecked = append(unchecked, name)
+	}
+	for len(unchecked) > 0 {
+		name := unchecked[len(unchecked)-1]
+		unchecked = unchecked[:len(unchecked)-1]
+
+		def, isDef := defs[name]
+		if !isDef {
+			continue
+		}
+		for defName := range findIdents(&ast.Block{[]ast.Node{def}}) {
+			if !used[defName] {
+				used[defName] = true
+				unchecked = append(unchecked, defName)
+			}
+		}
+	}
+
+	kept := make([]ast.Node, 0)
+	for _, line := range preludeLines {
+		assign, isAssign := line.(*ast.Assign)
+		if isAssign {
+			target, isIdent := assign.Target.(*ast.Ident)
+			if isIdent && !used[target.Value] {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	newLines := make([]ast.Node, 0)
+	newLines = append(newLines, lines[:preludeLoc]...)
+	newLines = append(newLines, kept...)
+	newLines = append(newLines, lines[preludeLoc:]...)
+	mainFunc.Body = &ast.Block{newLines}
+}
+
+func findIdents(block *ast.Block) map[string]bool {
+	finder := &identFinder{make(map[string]bool)}
+	ast.WalkBlock(block, finder)
+
+	return finder.names
+}
+
+func (f *identFinder) WalkNode(astNode ast.Node) ast.Node {
+	ident, isIdent := astNode.(*ast.Ident)
+	if isIdent {
+		f.names[ident.Value] = true
+	}
+
+	return nil
+}
+
+func (f *identFinder) WalkBlock(block *ast.Block) *ast.Block {
+	return nil
+}
@@ -11,11 +11,68 @@ type StructRemover struct {
 	structNo int
 }
 
+// typeDefFinder registers the enums and interfaces declared in the program
+type typeDefFinder struct {
+	prog *ast.Program
+}
+
 func RemoveStructs(prog *ast.Program) {
 	remover := &StructRemover{}
 	remover.prog = prog
 
-	prog.Funcs["main"].Body = ast.WalkBlock(prog.Funcs["main"].Body, remover)
+	// Enums and interfaces are collected first so they can be used anywhere in the program
+	ast.WalkBlock(prog.Funcs["main"].Body, &typeDefFinder{prog})
+
+	mainBody := ast.WalkBlock(prog.Funcs["main"].Body, remover)
+	prog.Funcs["main"].Body = &ast.Block{append(remover.variantConstructors(), mainBody.Lines...)}
+}
+
+func (f *typeDefFinder) WalkNode(astNode ast.Node) ast.Node {
+	switch node := astNode.(type) {
+	case *ast.EnumDef:
+		f.prog.AddEnum(node)
+	case *ast.InterfaceDef:
+		f.prog.AddInterface(node)
+	}
+
+	return nil
+}
+
+func (f *typeDefFinder) WalkBlock(block *ast.Block) *ast.Block {
+	return nil
+}
+
+// variantConstructors creates a constructor function for every enum variant
+func (r *StructRemover) variantConstructors() []ast.Node {
+	constructors := make([]ast.Node, 0)
+	for i := 0; i < r.prog.EnumCount(); i++ {
+		enumDef := r.prog.EnumNo(i)
+		for _, variant := range enumDef.Variants {
+			args := make([]ast.Node, len(variant.Types))
+			values := make([]ast.Node, len(variant.Types))
+			for k := range variant.Types {
+				args[k] = &ast.Ident{fmt.Sprintf("v%d", k), ast.NoID}
+				values[k] = &ast.Ident{fmt.Sprintf("v%d", k), ast.NoID}
+			}
+
+			constructor := &ast.FunDef{
+				Body: &ast.Block{
+					[]ast.Node{&ast.EnumInstance{variant.Name, values, enumDef, ast.NoID}},
+				},
+				Args:     args,
+				TypeHint: &types.FuncType{variant.Types, enumDef.Type},
+			}
+			constructors = append(constructors, &ast.Assign{&ast.Ident{variant.Name, ast.NoID}, constructor, ast.NoID})
+		}
+	}
+
+	return constructors
+}
+
+// nullaryVariant returns true if name is an enum variant without a payload
+func (r *StructRemover) nullaryVariant(name string) bool {
+	enumDef := r.prog.VariantEnum(name)
+	return enumDef != nil && len(enumDef.Variant(name).Types) == 0
 }
 
 func (r *StructRemover) WalkNode(astNode ast.Node) ast.Node {
@@ -47,14 +104,77 @@ func (r *StructRemover) WalkNode(astNode ast.Node) ast.Node {
 				}},
 			},
 			Args:     args,
-			TypeHint: &types.FuncType{argTypes, types.StructType{node.Type.Name}},
+			TypeHint: &types.FuncType{argTypes, types.StructType{Name: node.Type.Name}},
+		}
+		if node.Generic() {
+			// The type arguments of a generic struct are inferred from the values it's constructed with
+			constructor.TypeHint = nil
 		}
 		retVal = constructor
+	case *ast.EnumDef:
+		// The constructors for each variant are added to the start of the program
+		retVal = &ast.LineBundle{}
+	case *ast.InterfaceDef:
+		retVal = &ast.LineBundle{}
+	case *ast.Ident:
+		// Variants without a payload are used as values, so they're called implicitly
+		if r.nullaryVariant(node.Value) {
+			retVal = &ast.FunApp{&ast.Ident{node.Value, ast.NoID}, []ast.Node{}, false, node.NodeID}
+		}
+	case *ast.FunApp:
+		fun, isIdent := node.Fun.(*ast.Ident)
+		if isIdent && len(node.Args) == 1 && r.prog.Interface(fun.Value) != nil {
+			// Calling an interface converts a struct to it
+			retVal = &ast.InterfaceValue{ast.WalkAst(node.Args[0], r), r.prog.Interface(fun.Value).Type, node.NodeID}
+			break
+		}
+
+		// Don't call a variant that's already being called explicitly
+		if isIdent && r.nullaryVariant(fun.Value) {
+			retVal = &ast.FunApp{fun, ast.WalkList(node.Args, r), node.Extern, node.NodeID}
+		}
+	case *ast.Match:
+		newArms := make([]*ast.MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			newArms[i] = &ast.MatchArm{r.variantPatterns(arm.Pattern), nil, ast.WalkBlock(arm.Body, r)}
+			if arm.Guard != nil {
+				newArms[i].Guard = ast.WalkAst(arm.Guard, r)
+			}
+		}
+		retVal = &ast.Match{ast.WalkAst(node.Target, r), newArms, node.NodeID}
 	}
 
 	return retVal
 }
 
+// variantPatterns replaces the identifier patterns that name a variant with a pattern that matches the variant,
+// instead of binding a name
+func (r *StructRemover) variantPatterns(pattern ast.Node) ast.Node {
+	switch p := pattern.(type) {
+	case *ast.Ident:
+		if r.prog.VariantEnum(p.Value) != nil {
+			return &ast.VariantPattern{p.Value, []ast.Node{}, p.NodeID}
+		}
+	case *ast.VariantPattern:
+		return &ast.VariantPattern{p.Variant, r.subPatterns(p.Patterns), p.NodeID}
+	case *ast.TuplePattern:
+		return &ast.TuplePattern{r.subPatterns(p.Elems), p.NodeID}
+	case *ast.StructPattern:
+		return &ast.StructPattern{p.Name, p.Fields, r.subPatterns(p.Patterns), p.NodeID}
+	}
+
+	return pattern
+}
+
+func (r *StructRemover) subPatterns(patterns []ast.Node) []ast.Node {
+	newPatterns := make([]ast.Node, len(patterns))
+	for i, pattern := range patterns {
+		newPatterns[i] = r.variantPatterns(pattern)
+	}
+
+	return newPatterns
+}
+
 func (r *StructRemover) WalkBlock(block *ast.Block) *ast.Block {
 	return nil
 }
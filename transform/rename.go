@@ -2,6 +2,7 @@ package transform
 
 import (
 	"dandelion/ast"
+	"dandelion/errs"
 	"dandelion/parser"
 	"fmt"
 	"strings"
@@ -12,12 +13,18 @@ const NameSep = "_"
 type Renamer struct {
 	NameVersions map[string]int
 	LocalNames   map[string]string
+	Imports      map[string]string            // Paths of the modules imported by the current module, by the name they're imported as
+	Exports      map[string]map[string]string // The new names of each module's exported names, by module path
+	Prelude      map[string]string            // Paths of the prelude's modules, which every module imports, by name
 }
 
 func (r *Renamer) LocalCopy() *Renamer {
 	newRenamer := &Renamer{}
 	newRenamer.LocalNames = make(map[string]string)
 	newRenamer.NameVersions = r.NameVersions
+	newRenamer.Imports = r.Imports
+	newRenamer.Exports = r.Exports
+	newRenamer.Prelude = r.Prelude
 
 	for key, value := range r.LocalNames {
 		newRenamer.LocalNames[key] = value
@@ -48,6 +55,9 @@ func RenameIdents(prog *ast.Program) {
 	renamer := &Renamer{}
 	renamer.NameVersions = make(map[string]int)
 	renamer.LocalNames = make(map[string]string)
+	renamer.Imports = make(map[string]string)
+	renamer.Exports = make(map[string]map[string]string)
+	renamer.Prelude = make(map[string]string)
 
 	// Setup builtins
 	renamer.LocalNames["p"] = "p"
@@ -80,19 +90,155 @@ func (r *Renamer) WalkNode(astNode ast.Node) ast.Node {
 			newName = r.getName(node.Value)
 		}
 		retVal = &ast.Ident{newName, node.NodeID}
+	case *ast.FunApp:
+		// Named builtins like print are builtins unless the program binds its own
+		fun, isIdent := node.Fun.(*ast.Ident)
+		if !isIdent {
+			break
+		}
+		argCount, isBuiltin := ast.NamedBuiltins[ast.BuiltinName(fun.Value)]
+		_, isBound := r.LocalNames[fun.Value]
+		if isBuiltin && !isBound {
+			if argCount >= 0 && len(node.Args) != argCount {
+				errs.Error(errs.ErrorValue, node, "%s takes %d args, not %d", fun.Value, argCount, len(node.Args))
+				errs.CheckExit()
+			}
+			retVal = &ast.BuiltinExp{ast.WalkList(node.Args, r), ast.BuiltinName(fun.Value), node.NodeID}
+		}
 	case *ast.Extern:
 		r.LocalNames[node.Name] = node.Name
+	case *ast.Match:
+		newArms := make([]*ast.MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			// Names bound by a pattern are only in scope for that arm
+			armRenamer := r.LocalCopy()
+			pattern := armRenamer.renamePattern(arm.Pattern)
+			newArms[i] = &ast.MatchArm{pattern, nil, nil}
+			if arm.Guard != nil {
+				newArms[i].Guard = ast.WalkAst(arm.Guard, armRenamer)
+			}
+			newArms[i].Body = armRenamer.WalkBlock(arm.Body)
+		}
+		retVal = &ast.Match{ast.WalkAst(node.Target, r), newArms, node.NodeID}
+	case *ast.Module:
+		retVal = r.renameModule(node)
+	case *ast.Import:
+		r.Imports[node.Name] = node.Path
+		retVal = &ast.LineBundle{}
+	case *ast.StructAccess:
+		// Names exported by a module are accessed through the name it's imported as, unless it's shadowed
+		target, isIdent := node.Target.(*ast.Ident)
+		if !isIdent {
+			break
+		}
+		path, isImport := r.Imports[target.Value]
+		if !isImport {
+			path, isImport = r.Prelude[target.Value]
+		}
+		_, isShadowed := r.LocalNames[target.Value]
+		if !isImport || isShadowed {
+			break
+		}
+
+		field := node.Field.(*ast.Ident).Value
+		newName, isExported := r.Exports[path][field]
+		if !isExported {
+			errs.Error(errs.ErrorValue, node, "module '%s' has no exported name '%s'", target.Value, field)
+			errs.CheckExit()
+		}
+		retVal = &ast.Ident{newName, node.NodeID}
+	case *ast.TryCatch:
+		// The error name is only in scope for the catch block
+		catchRenamer := r.LocalCopy()
+		errName := catchRenamer.bindName(node.Err)
+		retVal = &ast.TryCatch{r.WalkBlock(node.Body), errName, catchRenamer.WalkBlock(node.Catch), node.NodeID}
 	}
 
 	return retVal
 }
 
+func (r *Renamer) renamePattern(pattern ast.Node) ast.Node {
+	switch p := pattern.(type) {
+	case *ast.Ident:
+		return r.bindName(p)
+	case *ast.TypePattern:
+		return &ast.TypePattern{r.bindName(p.Bind), p.Type, p.NodeID}
+	case *ast.VariantPattern:
+		return &ast.VariantPattern{p.Variant, r.renamePatterns(p.Patterns), p.NodeID}
+	case *ast.TuplePattern:
+		return &ast.TuplePattern{r.renamePatterns(p.Elems), p.NodeID}
+	case *ast.StructPattern:
+		return &ast.StructPattern{p.Name, p.Fields, r.renamePatterns(p.Patterns), p.NodeID}
+	}
+
+	return ast.WalkAst(pattern, r)
+}
+
+func (r *Renamer) renamePatterns(patterns []ast.Node) []ast.Node {
+	newPatterns := make([]ast.Node, len(patterns))
+	for i, pattern := range patterns {
+		newPatterns[i] = r.renamePattern(pattern)
+	}
+
+	return newPatterns
+}
+
+// bindName gives a name bound by a pattern a new version, so it shadows any variable with the same name
+func (r *Renamer) bindName(ident *ast.Ident) *ast.Ident {
+	delete(r.LocalNames, ident.Value)
+	return &ast.Ident{r.getName(ident.Value), ident.NodeID}
+}
+
 func (r *Renamer) WalkBlock(block *ast.Block) *ast.Block {
-	renameCopy := r.LocalCopy()
+	return &ast.Block{r.LocalCopy().renameLines(block.Lines)}
+}
 
+// renameLines renames a list of lines in the renamer's scope
+func (r *Renamer) renameLines(lines []ast.Node) []ast.Node {
 	newLines := make([]ast.Node, 0)
-	for _, line := range block.Lines {
-		newLines = append(newLines, ast.WalkAst(line, renameCopy))
+	for _, line := range lines {
+		newLine := ast.WalkAst(line, r)
+		bundle, isBundle := newLine.(*ast.LineBundle)
+		if isBundle {
+			newLines = append(newLines, bundle.Lines...)
+		} else {
+			newLines = append(newLines, newLine)
+		}
 	}
-	return &ast.Block{newLines}
+
+	return newLines
+}
+
+// renameModule renames a module in its own scope, so it can only see the names it defines and imports.
+// The names it defines are exported, except for externs and the ones that start with an underscore.
+func (r *Renamer) renameModule(module *ast.Module) ast.Node {
+	moduleRenamer := r.LocalCopy()
+	moduleRenamer.Imports = make(map[string]string)
+	lines := moduleRenamer.renameLines(module.Body.Lines)
+
+	// Externs are left for the module's own use, since another module can declare the same extern
+	externs := make(map[string]bool)
+	for _, line := range module.Body.Lines {
+		extern, isExtern := line.(*ast.Extern)
+		if isExtern {
+			externs[extern.Name] = true
+		}
+	}
+
+	exports := make(map[string]string)
+	for name, newName := range moduleRenamer.LocalNames {
+		inherited := r.LocalNames[name] == newName
+		if !inherited && !externs[name] && !strings.HasPrefix(name, "_") {
+			exports[name] = newName
+		}
+	}
+	r.Exports[module.Path] = exports
+
+	if parser.IsPrelude(module.Path) {
+		// The prelude is left as modules until the parts of it the program doesn't use are removed
+		r.Prelude[module.Name] = module.Path
+		return &ast.Module{module.Name, module.Path, &ast.Block{lines}, module.NodeID}
+	}
+
+	return &ast.LineBundle{lines, module.NodeID}
 }
@@ -7,9 +7,11 @@ import (
 func TransformAst(prog *ast.Program) {
 	RemoveStructs(prog)
 	RenameIdents(prog)
+	TrimPrelude(prog)
 	sources := RemFuncs(prog)
 	MarkCoroutines(prog)
 	RemovePipes(prog)
 	ExtractClosures(prog, sources)
 	FindTypeRefs(prog)
+	MarkDiscarded(prog)
 }
@@ -26,6 +26,8 @@ func (r *RefFinder) WalkNode(astNode ast.Node) ast.Node {
 		r.typeRefs[types.HashType(node.CheckType)] = node.CheckType
 	case *ast.TypeAssert:
 		r.typeRefs[types.HashType(node.TargetType)] = node.TargetType
+	case *ast.TypePattern:
+		r.typeRefs[types.HashType(node.Type)] = node.Type
 	}
 
 	return nil
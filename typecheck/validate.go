@@ -7,6 +7,7 @@ import (
 	"dandelion/types"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 type TypeValidator struct {
@@ -24,15 +25,19 @@ var Assignable = NodeList{&ast.Ident{}, &ast.StructAccess{}, &ast.SliceNode{}, &
 var Addable = TypeList{types.StringType{}, types.ByteType{}, types.IntType{}, types.FloatType{}}
 var Number = TypeList{types.ByteType{}, types.IntType{}, types.FloatType{}}
 var Natural = TypeList{types.IntType{}, types.ByteType{}}
-var Sliceable = TypeList{types.TupleType{}, types.ArrayType{}, types.StringType{}}
+var Sliceable = TypeList{types.TupleType{}, types.ArrayType{}, types.StringType{}, types.MapType{}}
+var RangeSliceable = TypeList{types.ArrayType{}, types.StringType{}}
 var Index = TypeList{types.IntType{}}
 var Conditional = TypeList{types.BoolType{}}
-var Iterable = TypeList{types.ArrayType{}, types.CoroutineType{}}
-var DotAccess = TypeList{types.StructType{}, types.ArrayType{}}
+var Iterable = TypeList{types.ArrayType{}, types.CoroutineType{}, types.MapType{}}
+var DotAccess = TypeList{types.StructType{}, types.ArrayType{}, types.MapType{}, types.StringType{}, types.InterfaceType{}}
 var Invocable = TypeList{types.FuncType{}}
-var Nullable = TypeList{types.CoroutineType{}, types.FuncType{}, types.StructType{}, types.TupleType{}, types.VoidType{}, types.ArrayType{}, types.AnyType{}}
+var Nullable = TypeList{types.CoroutineType{}, types.FuncType{}, types.StructType{}, types.TupleType{}, types.VoidType{}, types.ArrayType{}, types.AnyType{}, types.MapType{}, types.InterfaceType{}}
 var Ordered = TypeList{types.IntType{}, types.BoolType{}, types.FloatType{}, types.ByteType{}}
-var Lenable = TypeList{types.StringType{}, types.ArrayType{}, types.TupleType{}}
+var Lenable = TypeList{types.StringType{}, types.ArrayType{}, types.TupleType{}, types.MapType{}}
+var Hashable = TypeList{types.IntType{}, types.ByteType{}, types.BoolType{}, types.FloatType{}, types.StringType{}}
+var Formattable = TypeList{types.IntType{}, types.FloatType{}, types.BoolType{}, types.ByteType{}, types.StringType{}}
+var Convertible = TypeList{types.IntType{}, types.FloatType{}, types.BoolType{}, types.ByteType{}, types.StringType{}}
 
 func ValidateProg(prog *ast.Program, tys map[ast.NodeHash]types.Type) {
 	v := &TypeValidator{}
@@ -70,6 +75,16 @@ func (v *TypeValidator) isType(node ast.Node, list TypeList) bool {
 	return false
 }
 
+func (v *TypeValidator) hashable(ty types.Type) bool {
+	for _, item := range Hashable {
+		if item == ty {
+			return true
+		}
+	}
+
+	return false
+}
+
 func isNode(node ast.Node, list NodeList) bool {
 	for _, item := range list {
 		if reflect.TypeOf(item) == reflect.TypeOf(node) {
@@ -123,10 +138,32 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 			ty := v.Type(node.Arr)
 			errs.Error(errs.ErrorType, node.Arr, "type '%s' is not sliceable", ty.TypeString())
 		}
-		if !v.isType(node.Index, Index) {
+		mapType, isMap := v.Type(node.Arr).(types.MapType)
+		if isMap {
+			if !types.Equals(v.Type(node.Index), mapType.Key) {
+				ty := v.Type(node.Index)
+				errs.Error(errs.ErrorType, node.Index, "type '%s' is not a valid key for '%s'", ty.TypeString(), mapType.TypeString())
+			}
+		} else if !v.isType(node.Index, Index) {
 			ty := v.Type(node.Index)
 			errs.Error(errs.ErrorType, node.Index, "type '%s' is not a valid index", ty.TypeString())
 		}
+	case *ast.RangeSlice:
+		v.checkVoid(node.Arr)
+		if !v.likeType(node.Arr, RangeSliceable) {
+			ty := v.Type(node.Arr)
+			errs.Error(errs.ErrorType, node.Arr, "type '%s' can't be sliced by range", ty.TypeString())
+		}
+		for _, bound := range []ast.Node{node.Low, node.High} {
+			if bound == nil {
+				continue
+			}
+			v.checkVoid(bound)
+			if !v.isType(bound, Index) {
+				ty := v.Type(bound)
+				errs.Error(errs.ErrorType, bound, "type '%s' is not a valid index", ty.TypeString())
+			}
+		}
 	case *ast.TupleAccess:
 		// TODO implement this
 		if transform.IsCloArg(node.Tup) {
@@ -140,6 +177,11 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 			ty := v.Type(node.Iter)
 			errs.Error(errs.ErrorType, node.Iter, "type '%s' is not iterable", ty.TypeString())
 		}
+		_, isMap := v.Type(node.Iter).(types.MapType)
+		_, isPair := node.Item.(*ast.TupleLiteral)
+		if isMap != isPair {
+			errs.Error(errs.ErrorValue, node.Item, "maps must be iterated as (key, value) pairs")
+		}
 	case *ast.For:
 		v.checkVoid(node.Cond)
 		if !v.isType(node.Cond, Conditional) {
@@ -158,6 +200,17 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 			ty := v.Type(node.Cond)
 			errs.Error(errs.ErrorType, node.Cond, "type '%s' is not a valid conditional", ty.TypeString())
 		}
+		if !node.HasValue() || v.prog.Discarded(node) {
+			break
+		}
+		ifType := v.Type(node)
+		for _, branch := range node.Branches() {
+			lastLine := branch.Lines[len(branch.Lines)-1]
+			if !types.Equals(v.Type(lastLine), ifType) {
+				errs.Error(errs.ErrorType, lastLine, "if branches must all produce type '%s'", ifType.TypeString())
+				break
+			}
+		}
 	case *ast.StructAccess:
 		v.checkVoid(node.Target)
 		if !v.likeType(node.Target, DotAccess) {
@@ -196,6 +249,30 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 		if !v.isType(node.Right, Ordered) || !v.isType(node.Left, Ordered) {
 			errs.Error(errs.ErrorType, node, "operand isn't ordered")
 		}
+	case *ast.BitOp:
+		v.checkVoid(node.Left, node.Right)
+		if !v.isType(node.Left, Natural) || !v.isType(node.Right, Natural) {
+			errs.Error(errs.ErrorType, node, "operand isn't a natural number")
+		}
+	case *ast.Complement:
+		v.checkVoid(node.Target)
+		if !v.isType(node.Target, Natural) {
+			errs.Error(errs.ErrorType, node, "operand isn't a natural number")
+		}
+	case *ast.AndOr:
+		v.checkVoid(node.Left, node.Right)
+		for _, operand := range []ast.Node{node.Left, node.Right} {
+			if !v.isType(operand, Conditional) {
+				ty := v.Type(operand)
+				errs.Error(errs.ErrorType, operand, "type '%s' is not a valid operand for '%s'", ty.TypeString(), node.Op)
+			}
+		}
+	case *ast.Not:
+		v.checkVoid(node.Target)
+		if !v.isType(node.Target, Conditional) {
+			ty := v.Type(node.Target)
+			errs.Error(errs.ErrorType, node.Target, "type '%s' can't be negated", ty.TypeString())
+		}
 	case *ast.ArrayLiteral:
 		v.checkVoid(node.Exprs...)
 		if len(node.Exprs) < 1 {
@@ -208,6 +285,29 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 				break
 			}
 		}
+	case *ast.MapLiteral:
+		v.checkVoid(node.Keys...)
+		v.checkVoid(node.Values...)
+		mapType := v.Type(node).(types.MapType)
+		if !v.hashable(mapType.Key) {
+			errs.Error(errs.ErrorType, node, "type '%s' can't be used as a map key", mapType.Key.TypeString())
+		}
+		for k, key := range node.Keys {
+			if !types.Equals(v.Type(key), mapType.Key) || !types.Equals(v.Type(node.Values[k]), mapType.Value) {
+				errs.Error(errs.ErrorType, key, "map entries must be of same type")
+				break
+			}
+		}
+	case *ast.InExp:
+		v.checkVoid(node.Item, node.Container)
+		mapType, isMap := v.Type(node.Container).(types.MapType)
+		if !isMap {
+			ty := v.Type(node.Container)
+			errs.Error(errs.ErrorType, node.Container, "type '%s' doesn't support 'in'", ty.TypeString())
+		} else if !types.Equals(v.Type(node.Item), mapType.Key) {
+			ty := v.Type(node.Item)
+			errs.Error(errs.ErrorType, node.Item, "type '%s' is not a valid key for '%s'", ty.TypeString(), mapType.TypeString())
+		}
 	case *ast.Mod:
 		v.checkVoid(node.Left, node.Right)
 		if !v.isType(node.Left, Natural) || !v.isType(node.Right, Natural) {
@@ -230,9 +330,11 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 			}
 		case ast.BuiltinSend:
 			ty := v.Type(node.Args[0])
-			_, isCoro := ty.(types.CoroutineType)
+			coroType, isCoro := ty.(types.CoroutineType)
 			if !isCoro {
 				errs.Error(errs.ErrorType, node, "argument to send must be coroutine")
+			} else if !types.Equals(v.Type(node.Args[1]), coroType.Reads) {
+				errs.Error(errs.ErrorType, node, "can't send '%s' to coroutine that reads '%s'", v.Type(node.Args[1]).TypeString(), coroType.Reads.TypeString())
 			}
 		case ast.BuiltinLen:
 			if !v.likeType(node.Args[0], Lenable) {
@@ -247,12 +349,38 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 				if !isSubByte {
 					errs.Error(errs.ErrorValue, node, "invalid argument for str builtin")
 				}
+			} else if !v.isType(node.Args[0], Convertible) {
+				errs.Error(errs.ErrorType, node, "can't convert type '%s' to str", ty.TypeString())
+			}
+		case ast.BuiltinInt, ast.BuiltinFloat, ast.BuiltinByte:
+			if !v.isType(node.Args[0], Convertible) {
+				ty := v.Type(node.Args[0])
+				errs.Error(errs.ErrorType, node, "can't convert type '%s' to %s", ty.TypeString(), node.Type)
+			}
+		case ast.BuiltinParseInt, ast.BuiltinParseFloat, ast.BuiltinEnv:
+			if !v.isType(node.Args[0], TypeList{types.StringType{}}) {
+				ty := v.Type(node.Args[0])
+				errs.Error(errs.ErrorType, node, "argument to %s must be string, not '%s'", node.Type, ty.TypeString())
 			}
 		case ast.BuiltinAny:
 		case ast.BuiltinType:
+		case ast.BuiltinPrint:
+		case ast.BuiltinExitCode:
+		case ast.BuiltinArgv:
 		default:
 			panic("Validation step undefined for builtin: " + node.Type)
 		}
+	case *ast.Match:
+		v.checkMatch(node)
+	case *ast.EnumDef:
+		for _, variant := range node.Variants {
+			for _, ty := range variant.Types {
+				_, isVoid := ty.(types.VoidType)
+				if isVoid {
+					errs.Error(errs.ErrorValue, node, "void type not allowed in enum variant '%s'", variant.Name)
+				}
+			}
+		}
 	case *ast.StructDef:
 		for _, member := range node.Members {
 			_, isVoid := member.Type.(types.VoidType)
@@ -264,10 +392,36 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 		v.checkVoid(node.CheckNode)
 	case *ast.TypeAssert:
 		v.checkVoid(node.Target)
+	case *ast.InterfaceValue:
+		v.checkVoid(node.Value)
+		v.checkImplements(node)
 	case *ast.YieldExp:
 		v.checkVoid(node.Target)
+	case *ast.TryCatch:
+		// A coroutine can't suspend while its handler is active, errors would jump into a finished frame
+		if transform.HasYield(&ast.BlockExp{node.Body, ast.NoID}) {
+			errs.Error(errs.ErrorValue, node, "can't yield inside a try block")
+		}
+	case *ast.Throw:
+		v.checkVoid(node.Value)
 	case *ast.TupleLiteral:
 		v.checkVoid(node.Exprs...)
+	case *ast.CommandExp:
+		v.checkVoid(node.Args...)
+		for _, arg := range node.Args {
+			if !v.isType(arg, TypeList{types.StringType{}}) {
+				ty := v.Type(arg)
+				errs.Error(errs.ErrorType, arg, "command arguments must be strings, not '%s'", ty.TypeString())
+			}
+		}
+	case *ast.InterpStr:
+		v.checkVoid(node.Parts...)
+		for _, part := range node.Parts {
+			if !v.isType(part, Formattable) {
+				ty := v.Type(part)
+				errs.Error(errs.ErrorType, part, "type '%s' can't be interpolated into a string", ty.TypeString())
+			}
+		}
 	case *ast.Pipeline:
 		if !v.likeType(node.Ops[0], Iterable) {
 			errs.Error(errs.ErrorType, node, "pipeline start must be iterable")
@@ -291,6 +445,23 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 	case *ast.BlockExp:
 	case *ast.PipeExp:
 	case *ast.StructInstance:
+	case *ast.EnumInstance:
+	case *ast.VariantPattern:
+		enumType := v.Type(node).(types.EnumType)
+		if v.prog.Enum(enumType.Name).Variant(node.Variant) == nil {
+			errs.Error(errs.ErrorType, node, "'%s' is not a variant of enum '%s'", node.Variant, enumType.Name)
+		}
+	case *ast.TypePattern:
+		if !v.likeType(node, TypeList{types.AnyType{}}) {
+			ty := v.Type(node)
+			errs.Error(errs.ErrorType, node, "type pattern can't match value of type '%s', only 'any'", ty.TypeString())
+		}
+	case *ast.TuplePattern:
+		tupleType := v.Type(node).(types.TupleType)
+		if len(node.Elems) != len(tupleType.Types) {
+			errs.Error(errs.ErrorType, node, "tuple pattern has %d elements, but the value has %d", len(node.Elems), len(tupleType.Types))
+		}
+	case *ast.StructPattern:
 	case *ast.FunDef:
 	case *ast.Ident:
 	case *ast.Num:
@@ -306,6 +477,148 @@ func (v *TypeValidator) WalkNode(astNode ast.Node) ast.Node {
 	return nil
 }
 
+// checkMatch makes sure that every arm of a match is reachable, and that together the arms match every value
+func (v *TypeValidator) checkMatch(node *ast.Match) {
+	v.checkVoid(node.Target)
+
+	// Enums and bools have a fixed set of values that can be matched one at a time
+	var values []string
+	switch ty := v.Type(node.Target).(type) {
+	case types.EnumType:
+		for _, variant := range v.prog.Enum(ty.Name).Variants {
+			values = append(values, variant.Name)
+		}
+	case types.BoolType:
+		values = []string{"true", "false"}
+	}
+
+	covered := make(map[string]bool)
+	exhaustive := false
+	for _, arm := range node.Arms {
+		if exhaustive {
+			errs.Error(errs.ErrorValue, arm.Pattern, "unreachable match arm")
+			break
+		}
+
+		if arm.Guard != nil {
+			v.checkVoid(arm.Guard)
+			if !v.isType(arm.Guard, TypeList{types.BoolType{}}) {
+				errs.Error(errs.ErrorType, arm.Guard, "match guard must be a bool")
+			}
+			// The guard might be false, so the arm doesn't cover anything
+			continue
+		}
+
+		if irrefutable(arm.Pattern) {
+			exhaustive = true
+			continue
+		}
+
+		value, isSingle := singleValue(arm.Pattern)
+		if isSingle && covered[value] {
+			errs.Error(errs.ErrorValue, arm.Pattern, "'%s' is already matched", value)
+		} else if isSingle {
+			covered[value] = true
+		}
+		if values != nil && len(covered) == len(values) {
+			exhaustive = true
+		}
+	}
+
+	if !exhaustive && values != nil {
+		missing := make([]string, 0)
+		for _, value := range values {
+			if !covered[value] {
+				missing = append(missing, value)
+			}
+		}
+		errs.Error(errs.ErrorValue, node, "match is not exhaustive, missing: %s", strings.Join(missing, ", "))
+	} else if !exhaustive {
+		errs.Error(errs.ErrorValue, node, "match is not exhaustive, add an arm that matches any value")
+	}
+
+	if !node.HasValue() {
+		return
+	}
+	matchType := v.Type(node)
+	for _, arm := range node.Arms {
+		lastLine := arm.Body.Lines[len(arm.Body.Lines)-1]
+		if !types.Equals(v.Type(lastLine), matchType) {
+			errs.Error(errs.ErrorType, lastLine, "match arms must all produce type '%s'", matchType.TypeString())
+			break
+		}
+	}
+}
+
+// irrefutable returns true if a pattern matches every value it can be given
+func irrefutable(pattern ast.Node) bool {
+	switch p := pattern.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.TypePattern:
+		_, isAny := p.Type.(types.AnyType)
+		return isAny
+	case *ast.TuplePattern:
+		return allIrrefutable(p.Elems)
+	case *ast.StructPattern:
+		return allIrrefutable(p.Patterns)
+	}
+
+	return false
+}
+
+func allIrrefutable(patterns []ast.Node) bool {
+	for _, pattern := range patterns {
+		if !irrefutable(pattern) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// singleValue returns the name of the enum variant or bool that a pattern matches, if it matches all of
+// that value
+func singleValue(pattern ast.Node) (string, bool) {
+	switch p := pattern.(type) {
+	case *ast.VariantPattern:
+		return p.Variant, allIrrefutable(p.Patterns)
+	case *ast.BoolExp:
+		return fmt.Sprint(p.Value), true
+	}
+
+	return "", false
+}
+
 func (v *TypeValidator) WalkBlock(block *ast.Block) *ast.Block {
 	return nil
 }
+
+// checkImplements checks that the struct converted to an interface has every method of the interface
+func (v *TypeValidator) checkImplements(node *ast.InterfaceValue) {
+	structType, isStruct := v.Type(node.Value).(types.StructType)
+	if !isStruct {
+		errs.Error(errs.ErrorType, node, "only structs can be converted to interface '%s', not '%s'", node.Type.Name,
+			v.Type(node.Value).TypeString())
+		return
+	}
+
+	structDef := v.prog.Struct(structType.TypeString())
+	for _, ifaceMethod := range v.prog.Interface(node.Type.Name).Methods {
+		method := structDef.Method(ifaceMethod.Name.Value)
+		if method == nil {
+			errs.Error(errs.ErrorType, node, "struct '%s' doesn't implement '%s', it has no method '%s'",
+				structType.TypeString(), node.Type.Name, ifaceMethod.Name.Value)
+			continue
+		}
+
+		// The method's first argument is the struct itself
+		funType := v.Type(v.prog.Funcs[method.TargetName]).(types.FuncType)
+		methodType := types.FuncType{funType.ArgTypes[1:], funType.RetType}
+		if !types.Equals(methodType, ifaceMethod.Type) {
+			errs.Error(errs.ErrorType, node, "struct '%s' doesn't implement '%s', method '%s' is '%s' instead of '%s'",
+				structType.TypeString(), node.Type.Name, ifaceMethod.Name.Value, methodType.TypeString(),
+				ifaceMethod.Type.TypeString())
+		}
+	}
+}
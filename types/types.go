@@ -17,9 +17,13 @@ func init() {
 	gob.Register(ByteType{})
 	gob.Register(FloatType{})
 	gob.Register(ArrayType{})
+	gob.Register(MapType{})
 	gob.Register(CoroutineType{})
 	gob.Register(TupleType{})
 	gob.Register(StructType{})
+	gob.Register(ParamType{})
+	gob.Register(EnumType{})
+	gob.Register(InterfaceType{})
 	gob.Register(VoidType{})
 	gob.Register(AnyType{})
 	gob.Register(FuncType{})
@@ -64,7 +68,8 @@ func (i FloatType) TypeString() string {
 	return "float"
 }
 
-var ListMethods = []string{"push", "pop"}
+var ListMethods = []string{"push", "pop", "insert", "remove", "extend", "reverse", "clear", "index"}
+var StringMethods = []string{"split", "join", "trim", "find", "contains", "replace", "startswith", "endswith", "upper", "lower", "repeat"}
 
 type ArrayType struct {
 	Subtype Type
@@ -74,6 +79,17 @@ func (a ArrayType) TypeString() string {
 	return fmt.Sprintf("[]%s", a.Subtype.TypeString())
 }
 
+var MapMethods = []string{"delete"}
+
+type MapType struct {
+	Key   Type
+	Value Type
+}
+
+func (m MapType) TypeString() string {
+	return fmt.Sprintf("map[%s]%s", m.Key.TypeString(), m.Value.TypeString())
+}
+
 type TupleType struct {
 	Types []Type
 }
@@ -116,11 +132,48 @@ func (f FuncType) TypeString() string {
 }
 
 type StructType struct {
-	Name string
+	Name   string
+	Params []Type // The type arguments of an instance of a generic struct
 }
 
 func (f StructType) TypeString() string {
-	return f.Name
+	if len(f.Params) == 0 {
+		return f.Name
+	}
+
+	paramStrings := make([]string, 0)
+	for _, param := range f.Params {
+		paramStrings = append(paramStrings, param.TypeString())
+	}
+
+	return fmt.Sprintf("%s[%s]", f.Name, strings.Join(paramStrings, ", "))
+}
+
+// ParamType is a type parameter of a generic struct, like T in 'struct Stack[T]'
+type ParamType struct {
+	Name string
+}
+
+func (p ParamType) TypeString() string {
+	return p.Name
+}
+
+// EnumType is a tagged union declared with enum. Each value is one of the enum's variants.
+type EnumType struct {
+	Name string
+}
+
+func (e EnumType) TypeString() string {
+	return e.Name
+}
+
+// InterfaceType is a set of methods. Any struct that has all of them can be converted to the interface.
+type InterfaceType struct {
+	Name string
+}
+
+func (i InterfaceType) TypeString() string {
+	return i.Name
 }
 
 type CoroutineType struct {
@@ -143,7 +196,7 @@ func Equals(t1 Type, t2 Type) bool {
 	switch ty := t1.(type) {
 	case FuncType:
 		other, same := t2.(FuncType)
-		if same && Equals(ty.RetType, other.RetType) && len(ty.ArgTypes) == len(ty.ArgTypes) {
+		if same && Equals(ty.RetType, other.RetType) && len(ty.ArgTypes) == len(other.ArgTypes) {
 			for k, arg := range ty.ArgTypes {
 				if !Equals(arg, other.ArgTypes[k]) {
 					return false
@@ -158,6 +211,12 @@ func Equals(t1 Type, t2 Type) bool {
 			return true
 		}
 		return false
+	case MapType:
+		other, same := t2.(MapType)
+		if same && Equals(ty.Key, other.Key) && Equals(ty.Value, other.Value) {
+			return true
+		}
+		return false
 	case TupleType:
 		other, same := t2.(TupleType)
 		if same && len(ty.Types) == len(other.Types) {
@@ -171,7 +230,7 @@ func Equals(t1 Type, t2 Type) bool {
 		return false
 	case StructType:
 		other, same := t2.(StructType)
-		if same && other.Name == ty.Name {
+		if same && other.TypeString() == ty.TypeString() {
 			return true
 		}
 		return false
@@ -184,6 +243,86 @@ func Equals(t1 Type, t2 Type) bool {
 	return false
 }
 
+// Substitute replaces the type parameters in a type with their arguments
+func Substitute(t Type, args map[string]Type) Type {
+	switch ty := t.(type) {
+	case ParamType:
+		arg, ok := args[ty.Name]
+		if ok {
+			return arg
+		}
+	case ArrayType:
+		return ArrayType{Substitute(ty.Subtype, args)}
+	case MapType:
+		return MapType{Substitute(ty.Key, args), Substitute(ty.Value, args)}
+	case TupleType:
+		return TupleType{substituteAll(ty.Types, args)}
+	case FuncType:
+		funType := FuncType{substituteAll(ty.ArgTypes, args), nil}
+		if ty.RetType != nil {
+			funType.RetType = Substitute(ty.RetType, args)
+		}
+		return funType
+	case CoroutineType:
+		return CoroutineType{Substitute(ty.Yields, args), Substitute(ty.Reads, args)}
+	case StructType:
+		if len(ty.Params) > 0 {
+			return StructType{ty.Name, substituteAll(ty.Params, args)}
+		}
+	}
+
+	return t
+}
+
+func substituteAll(typs []Type, args map[string]Type) []Type {
+	newTypes := make([]Type, len(typs))
+	for k, ty := range typs {
+		if ty != nil {
+			newTypes[k] = Substitute(ty, args)
+		}
+	}
+
+	return newTypes
+}
+
+// GenericStructs returns the instances of generic structs that a type refers to, like Stack[int] in []Stack[int]
+func GenericStructs(t Type) []StructType {
+	found := make([]StructType, 0)
+	switch ty := t.(type) {
+	case ArrayType:
+		found = append(found, GenericStructs(ty.Subtype)...)
+	case MapType:
+		found = append(found, GenericStructs(ty.Key)...)
+		found = append(found, GenericStructs(ty.Value)...)
+	case TupleType:
+		for _, elem := range ty.Types {
+			found = append(found, GenericStructs(elem)...)
+		}
+	case FuncType:
+		for _, arg := range ty.ArgTypes {
+			if arg != nil {
+				found = append(found, GenericStructs(arg)...)
+			}
+		}
+		if ty.RetType != nil {
+			found = append(found, GenericStructs(ty.RetType)...)
+		}
+	case CoroutineType:
+		found = append(found, GenericStructs(ty.Yields)...)
+		found = append(found, GenericStructs(ty.Reads)...)
+	case StructType:
+		// Arguments are found first, so they can be set up before the struct that uses them
+		for _, param := range ty.Params {
+			found = append(found, GenericStructs(param)...)
+		}
+		if len(ty.Params) > 0 {
+			found = append(found, ty)
+		}
+	}
+
+	return found
+}
+
 type TypeHash string
 
 func HashType(t Type) TypeHash {